@@ -17,6 +17,8 @@ type closeRequest struct {
 // flushing aggregates to StatsD, useful if the frequency of events is extremely high
 // and sampling is not desirable
 type StatsdBuffer struct {
+	dropped       uint64      // accessed atomically, first for 64-bit alignment
+	stats         BufferStats // accessed atomically
 	statsd        Statsd
 	flushInterval time.Duration
 	eventChannel  chan event.Event
@@ -24,18 +26,59 @@ type StatsdBuffer struct {
 	closeChannel  chan closeRequest
 	Logger        Logger
 	Verbose       bool
+	// Percentiles to compute for Timing and PrecisionTiming events at each flush
+	// (e.g. []float64{50, 90, 99} to send ".p50", ".p90" and ".p99").
+	// Must be set before sending any event.
+	Percentiles []float64
+	// Overflow is what to do with new events when the queue is full (blocking by default).
+	// Must be set before sending any event.
+	Overflow OverflowPolicy
+	// Spool, if set, persists the events which can't be flushed to disk, instead of keeping
+	// them in memory, and sends them once the server is reachable again.
+	// Must be set before sending any event.
+	Spool *Spool
+	// OnError, if set, receives the errors flushing and aggregating the events,
+	// instead of the Logger (the metric functions never return them).
+	// Must be set before sending any event.
+	OnError   ErrorHandler
+	shards    shards     // if set, the events are aggregated here instead of by the collector
+	telemetry *telemetry // if set, the metrics about the client itself are sent at each flush
+	limiter   *cardinalityLimiter
 }
 
 // NewStatsdBuffer Factory
 func NewStatsdBuffer(interval time.Duration, client Statsd) *StatsdBuffer {
+	return newStatsdBuffer(interval, client, newOptions(nil))
+}
+
+func newStatsdBuffer(interval time.Duration, client Statsd, o *options) *StatsdBuffer {
+	if nil == o.logger {
+		o.logger = log.New(os.Stdout, "[BufferedStatsdClient] ", log.Ldate|log.Ltime)
+	}
 	sb := &StatsdBuffer{
 		flushInterval: interval,
 		statsd:        client,
-		eventChannel:  make(chan event.Event, 100),
+		eventChannel:  make(chan event.Event, o.channelCapacity),
 		events:        make(map[string]event.Event),
 		closeChannel:  make(chan closeRequest),
-		Logger:        log.New(os.Stdout, "[BufferedStatsdClient] ", log.Ldate|log.Ltime),
-		Verbose:       true,
+		Logger:        o.logger,
+		Verbose:       o.verbose,
+		Percentiles:   o.percentiles,
+		Overflow:      o.overflow,
+		Spool:         o.spool,
+		OnError:       o.onError,
+	}
+	var admit func(name string) (string, bool)
+	if o.maxKeys > 0 || len(o.prefixMaxKeys) > 0 {
+		sb.limiter = newCardinalityLimiter(o.limitPolicy, o.maxKeys, o.prefixMaxKeys)
+		admit = sb.admit
+	}
+	if o.shards > 0 {
+		// the limits are applied when the keys are added to the shards
+		sb.shards = newShards(o.shards, admit)
+	}
+	if "" != o.telemetry {
+		sb.telemetry = &telemetry{namespace: o.telemetry}
 	}
 	go sb.collector()
 	return sb
@@ -52,98 +95,139 @@ func (sb *StatsdBuffer) CreateTCPSocket() error {
 }
 
 // Incr - Increment a counter metric. Often used to note a particular event
-func (sb *StatsdBuffer) Incr(stat string, count int64) error {
+func (sb *StatsdBuffer) Incr(stat string, count int64, tags ...string) error {
+	if nil != sb.shards && 0 != count {
+		sb.shards.incr(stat, count, tags)
+		return nil
+	}
 	if 0 != count {
-		sb.eventChannel <- &event.Increment{Name: stat, Value: count}
+		sb.enqueue(&event.Increment{Name: stat, Value: count, Tags: tags})
 	}
 	return nil
 }
 
 // Decr - Decrement a counter metric. Often used to note a particular event
-func (sb *StatsdBuffer) Decr(stat string, count int64) error {
+func (sb *StatsdBuffer) Decr(stat string, count int64, tags ...string) error {
+	if nil != sb.shards && 0 != count {
+		sb.shards.incr(stat, -count, tags)
+		return nil
+	}
 	if 0 != count {
-		sb.eventChannel <- &event.Increment{Name: stat, Value: -count}
+		sb.enqueue(&event.Increment{Name: stat, Value: -count, Tags: tags})
 	}
 	return nil
 }
 
 // Timing - Track a duration event
-func (sb *StatsdBuffer) Timing(stat string, delta int64) error {
-	sb.eventChannel <- event.NewTiming(stat, delta)
+func (sb *StatsdBuffer) Timing(stat string, delta int64, tags ...string) error {
+	e := event.NewTiming(stat, delta, tags...)
+	e.Percentiles = sb.Percentiles
+	sb.enqueue(e)
 	return nil
 }
 
 // PrecisionTiming - Track a duration event
 // the time delta has to be a duration
-func (sb *StatsdBuffer) PrecisionTiming(stat string, delta time.Duration) error {
-	sb.eventChannel <- event.NewPrecisionTiming(stat, delta)
+func (sb *StatsdBuffer) PrecisionTiming(stat string, delta time.Duration, tags ...string) error {
+	e := event.NewPrecisionTiming(stat, delta, tags...)
+	e.Percentiles = sb.Percentiles
+	sb.enqueue(e)
 	return nil
 }
 
 // Gauge - Gauges are a constant data type. They are not subject to averaging,
 // and they don’t change unless you change them. That is, once you set a gauge value,
 // it will be a flat line on the graph until you change it again
-func (sb *StatsdBuffer) Gauge(stat string, value int64) error {
-	sb.eventChannel <- &event.Gauge{Name: stat, Value: value}
+func (sb *StatsdBuffer) Gauge(stat string, value int64, tags ...string) error {
+	if nil != sb.shards {
+		sb.shards.gauge(stat, value, tags)
+		return nil
+	}
+	sb.enqueue(&event.Gauge{Name: stat, Value: value, Tags: tags})
 	return nil
 }
 
 // GaugeDelta records a delta from the previous value (as int64)
-func (sb *StatsdBuffer) GaugeDelta(stat string, value int64) error {
-	sb.eventChannel <- &event.GaugeDelta{Name: stat, Value: value}
+func (sb *StatsdBuffer) GaugeDelta(stat string, value int64, tags ...string) error {
+	sb.enqueue(&event.GaugeDelta{Name: stat, Value: value, Tags: tags})
 	return nil
 }
 
 // FGauge is a Gauge working with float64 values
-func (sb *StatsdBuffer) FGauge(stat string, value float64) error {
-	sb.eventChannel <- &event.FGauge{Name: stat, Value: value}
+func (sb *StatsdBuffer) FGauge(stat string, value float64, tags ...string) error {
+	sb.enqueue(&event.FGauge{Name: stat, Value: value, Tags: tags})
 	return nil
 }
 
 // FGaugeDelta records a delta from the previous value (as float64)
-func (sb *StatsdBuffer) FGaugeDelta(stat string, value float64) error {
-	sb.eventChannel <- &event.FGaugeDelta{Name: stat, Value: value}
+func (sb *StatsdBuffer) FGaugeDelta(stat string, value float64, tags ...string) error {
+	sb.enqueue(&event.FGaugeDelta{Name: stat, Value: value, Tags: tags})
 	return nil
 }
 
 // Absolute - Send absolute-valued metric (not averaged/aggregated)
-func (sb *StatsdBuffer) Absolute(stat string, value int64) error {
-	sb.eventChannel <- &event.Absolute{Name: stat, Values: []int64{value}}
+func (sb *StatsdBuffer) Absolute(stat string, value int64, tags ...string) error {
+	sb.enqueue(&event.Absolute{Name: stat, Values: []int64{value}, Tags: tags})
 	return nil
 }
 
 // FAbsolute - Send absolute-valued metric (not averaged/aggregated)
-func (sb *StatsdBuffer) FAbsolute(stat string, value float64) error {
-	sb.eventChannel <- &event.FAbsolute{Name: stat, Values: []float64{value}}
+func (sb *StatsdBuffer) FAbsolute(stat string, value float64, tags ...string) error {
+	sb.enqueue(&event.FAbsolute{Name: stat, Values: []float64{value}, Tags: tags})
 	return nil
 }
 
 // Total - Send a metric that is continously increasing, e.g. read operations since boot
-func (sb *StatsdBuffer) Total(stat string, value int64) error {
-	sb.eventChannel <- &event.Total{Name: stat, Value: value}
+func (sb *StatsdBuffer) Total(stat string, value int64, tags ...string) error {
+	sb.enqueue(&event.Total{Name: stat, Value: value, Tags: tags})
+	return nil
+}
+
+// Set - Count the unique values seen for a metric (e.g. unique users per interval).
+// Duplicate values are only sent once per flush interval
+func (sb *StatsdBuffer) Set(stat string, value string, tags ...string) error {
+	sb.enqueue(event.NewSet(stat, value, tags...))
+	return nil
+}
+
+// Histogram - Send a value to be aggregated into a statistical distribution by the server (per host).
+// The raw values are kept until the next flush, up to event.MaxSamples per key
+func (sb *StatsdBuffer) Histogram(stat string, value float64, tags ...string) error {
+	sb.enqueue(event.NewHistogram(stat, value, tags...))
+	return nil
+}
+
+// Distribution - Send a value to be aggregated into a statistical distribution
+// by the server (globally, across all hosts).
+// The raw values are kept until the next flush, up to event.MaxSamples per key
+func (sb *StatsdBuffer) Distribution(stat string, value float64, tags ...string) error {
+	sb.enqueue(event.NewDistribution(stat, value, tags...))
 	return nil
 }
 
 // SendEvents - Sends stats from all the event objects.
 func (sb *StatsdBuffer) SendEvents(events map[string]event.Event) error {
 	for _, e := range events {
-		sb.eventChannel <- e
+		sb.enqueue(e)
 	}
 	return nil
 }
 
-// avoid too many allocations by memoizing the "type|key" pair for an event
+// avoid too many allocations by memoizing the "type|key|tags" triple for an event
 // @see https://gobyexample.com/closures
-func initMemoisedKeyMap() func(typ string, key string) string {
-	m := make(map[string]map[string]string)
-	return func(typ string, key string) string {
+func initMemoisedKeyMap() func(typ string, key string, tags string) string {
+	m := make(map[string]map[string]map[string]string)
+	return func(typ string, key string, tags string) string {
 		if _, ok := m[typ]; !ok {
-			m[typ] = make(map[string]string)
+			m[typ] = make(map[string]map[string]string)
 		}
-		k, ok := m[typ][key]
+		if _, ok := m[typ][key]; !ok {
+			m[typ][key] = make(map[string]string)
+		}
+		k, ok := m[typ][key][tags]
 		if !ok {
-			m[typ][key] = typ + "|" + key
-			return m[typ][key]
+			m[typ][key][tags] = typ + "|" + key + "|" + tags
+			return m[typ][key][tags]
 		}
 		return k // memoized value
 	}
@@ -155,10 +239,7 @@ func (sb *StatsdBuffer) collector() {
 	defer func(sb *StatsdBuffer) {
 		if r := recover(); r != nil {
 			sb.Logger.Println("Caught panic, flushing stats before throwing the panic again")
-			err := sb.flush()
-			if nil != err {
-				sb.Logger.Println("Error flushing stats", err.Error())
-			}
+			sb.flushAndReport()
 			panic(r)
 		}
 	}(sb)
@@ -166,40 +247,84 @@ func (sb *StatsdBuffer) collector() {
 	keyFor := initMemoisedKeyMap() // avoid allocations (https://gobyexample.com/closures)
 
 	ticker := time.NewTicker(sb.flushInterval)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
 			//sb.Logger.Println("Flushing stats")
-			err := sb.flush()
-			if nil != err {
-				sb.Logger.Println("Error flushing stats", err.Error())
-			}
+			sb.mergeShards(keyFor)
+			sb.flushAndReport()
 		case e := <-sb.eventChannel:
 			//sb.Logger.Println("Received ", e.String())
-			// issue #28: unable to use Incr and PrecisionTiming with the same key (also fixed #27)
-			k := keyFor(e.TypeString(), e.Key()) // avoid allocations
-			if e2, ok := sb.events[k]; ok {
-				//sb.Logger.Println("Updating existing event")
-				err := e2.Update(e)
-				if nil != err {
-					sb.Logger.Println("Error updating stats", err.Error())
-				}
-				sb.events[k] = e2
-			} else {
-				//sb.Logger.Println("Adding new event")
-				sb.events[k] = e
-			}
+			sb.update(e, keyFor)
 		case c := <-sb.closeChannel:
 			if sb.Verbose {
 				sb.Logger.Println("Asked to terminate. Flushing stats before returning.")
 			}
+			// drain the events already queued, or they would be lost
+			for drained := false; !drained; {
+				select {
+				case e := <-sb.eventChannel:
+					sb.update(e, keyFor)
+				default:
+					drained = true
+				}
+			}
+			sb.mergeShards(keyFor)
 			c.reply <- sb.flush()
 			return
 		}
 	}
 }
 
+// update aggregates a new event into the pending events map.
+// This function is NOT thread-safe, so it must only be invoked synchronously
+// from within the collector() goroutine
+func (sb *StatsdBuffer) update(e event.Event, keyFor func(typ string, key string, tags string) string) {
+	// issue #28: unable to use Incr and PrecisionTiming with the same key (also fixed #27)
+	// events with the same name but a different tag set are aggregated separately
+	k := keyFor(e.TypeString(), e.Key(), event.TagKey(e.GetTags())) // avoid allocations
+	if _, ok := sb.events[k]; !ok && nil != sb.limiter {
+		if e = sb.limit(e); nil == e {
+			return // dropped
+		}
+		k = keyFor(e.TypeString(), e.Key(), event.TagKey(e.GetTags()))
+	}
+	sb.add(e, k)
+}
+
+// add an event to the pending events map, aggregating it with the event with the same key, if any.
+// This function is NOT thread-safe, so it must only be invoked synchronously
+// from within the collector() goroutine
+func (sb *StatsdBuffer) add(e event.Event, k string) {
+	e2, ok := sb.events[k]
+	if ok {
+		//sb.Logger.Println("Updating existing event")
+		err := e2.Update(e)
+		if nil != err {
+			sb.typeConflict(e, err)
+		}
+		sb.events[k] = e2
+	} else {
+		//sb.Logger.Println("Adding new event")
+		sb.events[k] = e
+	}
+}
+
+// mergeShards moves the events aggregated in the shards, if any, to the pending events map.
+// This function is NOT thread-safe, so it must only be invoked synchronously
+// from within the collector() goroutine
+func (sb *StatsdBuffer) mergeShards(keyFor func(typ string, key string, tags string) string) {
+	if nil == sb.shards {
+		return
+	}
+	sb.shards.drain(func(e event.Event) {
+		// the cardinality limits were applied by the shards
+		sb.add(e, keyFor(e.TypeString(), e.Key(), event.TagKey(e.GetTags())))
+	})
+}
+
 // Close sends a close event to the collector asking to stop & flush pending stats
 // and closes the statsd client
 func (sb *StatsdBuffer) Close() (err error) {
@@ -220,15 +345,70 @@ func (sb *StatsdBuffer) Close() (err error) {
 // This function is NOT thread-safe, so it must only be invoked synchronously
 // from within the collector() goroutine
 func (sb *StatsdBuffer) flush() (err error) {
+	sb.addTelemetry()
+	if nil != sb.Spool {
+		// send the spooled events first, to keep them in order
+		if err := sb.Spool.replay(sb.statsd.SendEvents); err != nil {
+			return sb.spool(&Error{Kind: ErrorFlush, Err: err})
+		}
+	}
 	n := len(sb.events)
 	if n == 0 {
 		return nil
 	}
-	if err := sb.statsd.SendEvents(sb.events); err != nil {
-		sb.Logger.Println(err)
-		return err
+	start := time.Now()
+	err = sb.statsd.SendEvents(sb.events)
+	sb.flushed(start, n, err)
+	if err != nil {
+		return sb.spool(&Error{Kind: ErrorFlush, Keys: eventKeys(sb.events), Err: err})
 	}
-	sb.events = make(map[string]event.Event)
+	sb.resetEvents()
 
 	return nil
 }
+
+// flushAndReport flushes the events, passing the error, if any, to the error handler.
+// This function is NOT thread-safe, so it must only be invoked synchronously
+// from within the collector() goroutine
+func (sb *StatsdBuffer) flushAndReport() {
+	err := sb.flush()
+	if nil == err {
+		return
+	}
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Kind: ErrorFlush, Err: err}
+	}
+	handleError(sb.OnError, sb.Logger, e, "Error flushing stats", err.Error())
+}
+
+// typeConflict reports an event which can't be aggregated with the previous one with the same key
+func (sb *StatsdBuffer) typeConflict(e event.Event, err error) {
+	handleError(sb.OnError, sb.Logger, &Error{Kind: ErrorTypeConflict, Keys: []string{e.Key()}, Err: err},
+		"Error updating stats", err.Error())
+}
+
+// resetEvents empties the pending events map, once flushed or spooled
+func (sb *StatsdBuffer) resetEvents() {
+	sb.events = make(map[string]event.Event)
+	if nil != sb.limiter {
+		sb.limiter.reset()
+	}
+}
+
+// spool persists the events which couldn't be sent, if there's a Spool, and resets them.
+// The events are kept in memory if they can't be spooled.
+// This function is NOT thread-safe, so it must only be invoked synchronously
+// from within the collector() goroutine
+func (sb *StatsdBuffer) spool(err error) error {
+	if nil == sb.Spool || 0 == len(sb.events) {
+		return err
+	}
+	if err2 := sb.Spool.write(sb.events); err2 != nil {
+		handleError(sb.OnError, sb.Logger, &Error{Kind: ErrorFlush, Keys: eventKeys(sb.events), Err: err2},
+			"Error spooling stats", err2.Error())
+		return err
+	}
+	sb.resetEvents()
+	return err
+}
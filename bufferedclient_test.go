@@ -584,3 +584,140 @@ func TestBufferedFAbsolute(t *testing.T) {
 		})
 	}
 }
+
+func TestBufferedTags(t *testing.T) {
+	client := NewStatsdClient("127.0.0.1:1201", "test.")
+	mock := &MockNetConn{}
+	client.conn = mock
+	buffered := NewStatsdBuffer(time.Hour, client)
+	buffered.Verbose = false
+
+	buffered.Incr("req", 1, "env:prod", "route:/api")
+	buffered.Incr("req", 2, "route:/api", "env:prod") // same tag set, different order
+	buffered.Incr("req", 4, "env:dev")
+	buffered.Incr("req", 8)
+
+	if err := buffered.Close(); nil != err {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for _, x := range strings.Split(mock.buf.String(), "\n") {
+		if x = strings.TrimSpace(x); "" != x {
+			actual = append(actual, x)
+		}
+	}
+	sort.Strings(actual)
+	expected := []string{
+		"test.req:3|c|#env:prod,route:/api",
+		"test.req:4|c|#env:dev",
+		"test.req:8|c",
+	}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, actual, actual)
+	}
+}
+
+func TestBufferedSet(t *testing.T) {
+	client := NewStatsdClient("127.0.0.1:1201", "test.")
+	mock := &MockNetConn{}
+	client.conn = mock
+	buffered := NewStatsdBuffer(time.Hour, client)
+	buffered.Verbose = false
+
+	buffered.Set("users", "alice")
+	buffered.Set("users", "bob")
+	buffered.Set("users", "alice") // duplicate, only sent once
+	buffered.Set("users", "carol", "env:prod")
+
+	if err := buffered.Close(); nil != err {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for _, x := range strings.Split(mock.buf.String(), "\n") {
+		if x = strings.TrimSpace(x); "" != x {
+			actual = append(actual, x)
+		}
+	}
+	sort.Strings(actual)
+	expected := []string{
+		"test.users:alice|s",
+		"test.users:bob|s",
+		"test.users:carol|s|#env:prod",
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, actual, actual)
+	}
+}
+
+func TestBufferedHistogram(t *testing.T) {
+	client := NewStatsdClient("127.0.0.1:1201", "test.")
+	mock := &MockNetConn{}
+	client.conn = mock
+	buffered := NewStatsdBuffer(time.Hour, client)
+	buffered.Verbose = false
+
+	buffered.Histogram("size", 10)
+	buffered.Histogram("size", 2.5)
+	buffered.Histogram("size", 10) // raw values are kept, even if duplicate
+	buffered.Distribution("latency", 0.5)
+
+	if err := buffered.Close(); nil != err {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for _, x := range strings.Split(mock.buf.String(), "\n") {
+		if x = strings.TrimSpace(x); "" != x {
+			actual = append(actual, x)
+		}
+	}
+	sort.Strings(actual)
+	expected := []string{
+		"test.latency:0.5|d",
+		"test.size:10|h",
+		"test.size:10|h",
+		"test.size:2.5|h",
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, actual, actual)
+	}
+}
+
+func TestBufferedPercentiles(t *testing.T) {
+	client := NewStatsdClient("127.0.0.1:1201", "test.")
+	mock := &MockNetConn{}
+	client.conn = mock
+	buffered := NewStatsdBuffer(time.Hour, client)
+	buffered.Verbose = false
+	buffered.Percentiles = []float64{50, 90}
+
+	for i := int64(1); i <= 10; i++ {
+		buffered.Timing("latency", i*10)
+	}
+
+	if err := buffered.Close(); nil != err {
+		t.Fatal(err)
+	}
+
+	re := regexp.MustCompile(`^test\.latency\.(p\d+):(\d+)\|ms$`)
+	actual := make(map[string]int64)
+	for _, x := range strings.Split(mock.buf.String(), "\n") {
+		if vv := re.FindStringSubmatch(strings.TrimSpace(x)); nil != vv {
+			v, _ := strconv.ParseInt(vv[2], 10, 64)
+			actual[vv[1]] = v
+		}
+	}
+	expected := map[string]int64{"p50": 50, "p90": 90}
+	if len(expected) != len(actual) {
+		t.Fatalf("did not receive all metrics: Expected: %v, Actual: %v ", expected, actual)
+	}
+	for k, v := range expected {
+		// within the sketch relative accuracy
+		if math.Abs(float64(actual[k]-v)) > 1 {
+			t.Errorf("wrong percentile %s: Expected: %d, Actual: %d", k, v, actual[k])
+		}
+	}
+}
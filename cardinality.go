@@ -0,0 +1,144 @@
+package statsd
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/quipo/statsd/event"
+)
+
+// CardinalityPolicy is what StatsdBuffer does with the events of a new key
+// when the maximum number of distinct keys per flush interval is reached
+type CardinalityPolicy int
+
+// cardinality policies
+const (
+	// CardinalityDrop drops the events of the new keys
+	CardinalityDrop CardinalityPolicy = iota
+	// CardinalityFold aggregates the events of the new keys into an "other" key, without tags:
+	// the prefix of the limit followed by "other" (e.g. "users.other"), or just "other"
+	// for the global limit. The custom events (not built-in) are dropped.
+	CardinalityFold
+)
+
+// String returns the name of the policy
+func (p CardinalityPolicy) String() string {
+	switch p {
+	case CardinalityDrop:
+		return "drop"
+	case CardinalityFold:
+		return "fold"
+	}
+	return "unknown"
+}
+
+// foldKey is the name of the key the events beyond the limits are folded into
+const foldKey = "other"
+
+// cardinalityLimiter caps the number of distinct keys aggregated per flush interval,
+// globally and for the names starting with some prefixes
+type cardinalityLimiter struct {
+	policy   CardinalityPolicy
+	max      int            // for all the keys, 0 for no limit
+	prefixes map[string]int // max keys for the names with each prefix
+
+	mu        sync.Mutex // the shards admit their keys concurrently
+	keys      int
+	perPrefix map[string]int
+}
+
+func newCardinalityLimiter(policy CardinalityPolicy, max int, prefixes map[string]int) *cardinalityLimiter {
+	return &cardinalityLimiter{
+		policy:    policy,
+		max:       max,
+		prefixes:  prefixes,
+		perPrefix: make(map[string]int, len(prefixes)),
+	}
+}
+
+// prefixOf returns the longest prefix with a limit of a metric name, if any
+func (l *cardinalityLimiter) prefixOf(name string) (string, bool) {
+	found, ok := "", false
+	for prefix := range l.prefixes {
+		if strings.HasPrefix(name, prefix) && len(prefix) >= len(found) {
+			found, ok = prefix, true
+		}
+	}
+	return found, ok
+}
+
+// admit counts a new key, returning false if it's beyond the limits,
+// with the prefix of the limit reached (if any)
+func (l *cardinalityLimiter) admit(name string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prefix, ok := l.prefixOf(name)
+	if ok && l.perPrefix[prefix] >= l.prefixes[prefix] {
+		return prefix, false
+	}
+	if l.max > 0 && l.keys >= l.max {
+		return "", false
+	}
+	l.keys++
+	if ok {
+		l.perPrefix[prefix]++
+	}
+	return "", true
+}
+
+// reset the counts, when the pending events are flushed
+func (l *cardinalityLimiter) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.keys = 0
+	for prefix := range l.perPrefix {
+		delete(l.perPrefix, prefix)
+	}
+}
+
+// Rejected returns the number of events dropped or folded because of the cardinality limits
+func (sb *StatsdBuffer) Rejected() uint64 {
+	return atomic.LoadUint64(&sb.stats.EventsRejected)
+}
+
+// admit applies the cardinality limits to a new key. Beyond them, it returns false, with the name
+// to fold its events into, or "" if they're dropped. Thread-safe.
+func (sb *StatsdBuffer) admit(name string) (string, bool) {
+	prefix, ok := sb.limiter.admit(name)
+	if ok {
+		return name, true
+	}
+	atomic.AddUint64(&sb.stats.EventsRejected, 1)
+	if CardinalityFold != sb.limiter.policy {
+		return "", false
+	}
+	return prefix + foldKey, false
+}
+
+// limit applies the cardinality limits to the event of a new key, returning the event
+// to aggregate (a renamed copy if folded), or nil if it's dropped.
+// This function is NOT thread-safe, so it must only be invoked synchronously
+// from within the collector() goroutine
+func (sb *StatsdBuffer) limit(e event.Event) event.Event {
+	name, ok := sb.admit(e.Key())
+	switch {
+	case ok:
+		return e
+	case "" == name:
+		return nil
+	}
+	return foldedCopy(e, name)
+}
+
+// foldedCopy returns a copy of an event with a new name and without tags, so that the event
+// of the caller (e.g. passed to SendEvents) is not modified, or nil if it's not a built-in event
+func foldedCopy(e event.Event, name string) event.Event {
+	c := copyEvent(e)
+	if nil == c {
+		return nil
+	}
+	c.SetKey(name)
+	c.SetTags(nil)
+	return c
+}
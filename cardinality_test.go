@@ -0,0 +1,137 @@
+package statsd
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/quipo/statsd/event"
+)
+
+func TestCardinalityLimiter(t *testing.T) {
+	l := newCardinalityLimiter(CardinalityDrop, 3, map[string]int{"users.": 1, "users.admin.": 2})
+	tests := []struct {
+		name   string
+		prefix string
+		ok     bool
+	}{
+		{"users.1", "", true},
+		{"users.2", "users.", false},
+		{"users.admin.1", "", true}, // the longest prefix applies
+		{"users.admin.2", "", true},
+		{"users.admin.3", "users.admin.", false},
+		{"a", "", false}, // global limit
+	}
+	for _, tt := range tests {
+		prefix, ok := l.admit(tt.name)
+		if tt.ok != ok || tt.prefix != prefix {
+			t.Errorf("%s: Expected: %q %v, Actual: %q %v", tt.name, tt.prefix, tt.ok, prefix, ok)
+		}
+	}
+	l.reset()
+	if _, ok := l.admit("a"); !ok {
+		t.Error("the counts should be reset")
+	}
+}
+
+func TestBufferedCardinalityLimit(t *testing.T) {
+	tests := []struct {
+		policy   CardinalityPolicy
+		expected []string
+	}{
+		{CardinalityDrop, []string{"a:2|c", "b:1|c", "users.1:1|c"}},
+		{CardinalityFold, []string{"a:2|c", "b:1|c", "other:1|c", "users.1:1|c", "users.other:2|c"}},
+	}
+	for _, tt := range tests {
+		client := &flakyClient{}
+		buffered, err := NewStatsdBufferWithOptions(time.Hour, client,
+			WithCardinalityLimit(3, tt.policy),
+			WithPrefixCardinalityLimit("users.", 1),
+			WithVerbose(false),
+		)
+		if nil != err {
+			t.Fatal(err)
+		}
+		buffered.Incr("users.1", 1)
+		buffered.Incr("users.2", 1)
+		buffered.Incr("users.3", 1, "id:3")
+		buffered.Incr("a", 1)
+		buffered.Incr("b", 1)
+		buffered.Incr("c", 1)
+		buffered.Incr("a", 1) // not a new key
+		if err = buffered.Close(); nil != err {
+			t.Fatal(err)
+		}
+
+		sort.Strings(client.lines)
+		if !reflect.DeepEqual(tt.expected, client.lines) {
+			t.Errorf("%s: did not receive all metrics: Expected: %T %v, Actual: %T %v ", tt.policy, tt.expected, tt.expected, client.lines, client.lines)
+		}
+		if 3 != buffered.Rejected() {
+			t.Errorf("%s: wrong number of rejected events: Expected: 3, Actual: %d", tt.policy, buffered.Rejected())
+		}
+	}
+
+	if _, err := NewStatsdBufferWithOptions(time.Hour, &flakyClient{}, WithPrefixCardinalityLimit("a.", -1)); nil == err {
+		t.Error("expected an error for a negative limit")
+	}
+}
+
+func TestShardedCardinalityLimit(t *testing.T) {
+	client := &flakyClient{}
+	buffered, err := NewStatsdBufferWithOptions(time.Hour, client,
+		WithShards(4),
+		WithCardinalityLimit(3, CardinalityFold),
+		WithVerbose(false),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		buffered.Incr("req."+strconv.Itoa(i), 1)
+		buffered.Timing("db."+strconv.Itoa(i), 5)
+	}
+
+	// the shards only hold the admitted keys, and the "other" keys
+	keys := 0
+	for _, sh := range buffered.shards {
+		sh.mu.RLock()
+		keys += len(sh.counters) + len(sh.events)
+		sh.mu.RUnlock()
+	}
+	if keys > 5 {
+		t.Errorf("the shards hold too many keys: %d", keys)
+	}
+	if err = buffered.Close(); nil != err {
+		t.Fatal(err)
+	}
+	if 197 != buffered.Rejected() {
+		t.Errorf("wrong number of rejected events: Expected: 197, Actual: %d", buffered.Rejected())
+	}
+}
+
+func TestFoldCopiesEvent(t *testing.T) {
+	client := &flakyClient{}
+	buffered, err := NewStatsdBufferWithOptions(time.Hour, client,
+		WithCardinalityLimit(1, CardinalityFold),
+		WithVerbose(false),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	buffered.Incr("a", 1)
+	e := &event.Increment{Name: "b", Value: 2, Tags: []string{"env:prod"}}
+	buffered.SendEvents(map[string]event.Event{"b": e})
+	if err = buffered.Close(); nil != err {
+		t.Fatal(err)
+	}
+	if "b" != e.Name || 1 != len(e.Tags) {
+		t.Errorf("the event of the caller was modified: %v", e)
+	}
+	sort.Strings(client.lines)
+	if expected := []string{"a:1|c", "other:2|c"}; !reflect.DeepEqual(expected, client.lines) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, client.lines, client.lines)
+	}
+}
@@ -0,0 +1,177 @@
+package statsd
+
+import (
+	"time"
+
+	"github.com/quipo/statsd/event"
+)
+
+// ChildClient is a lightweight view of a client adding a prefix to the metric names
+// (after the prefix of the client), and tags to the metrics (before their own tags).
+// It shares the connection, or the collector, of its parent: creating its socket
+// or closing it does nothing.
+type ChildClient struct {
+	parent Statsd
+	prefix string
+	tags   []string
+}
+
+func newChildClient(parent Statsd, prefix string, tags []string) *ChildClient {
+	return &ChildClient{parent: parent, prefix: prefix, tags: tags[:len(tags):len(tags)]}
+}
+
+// WithPrefix returns a view of the client adding a prefix to the metric names,
+// e.g. "db." to send "myproject.db.query" for "query"
+func (c *StatsdClient) WithPrefix(sub string) Statsd {
+	return newChildClient(c, sub, nil)
+}
+
+// WithTags returns a view of the client adding tags to all the metrics
+func (c *StatsdClient) WithTags(tags ...string) Statsd {
+	return newChildClient(c, "", tags)
+}
+
+// WithPrefix returns a view of the buffered client adding a prefix to the metric names
+func (sb *StatsdBuffer) WithPrefix(sub string) Statsd {
+	return newChildClient(sb, sub, nil)
+}
+
+// WithTags returns a view of the buffered client adding tags to all the metrics
+func (sb *StatsdBuffer) WithTags(tags ...string) Statsd {
+	return newChildClient(sb, "", tags)
+}
+
+// WithPrefix returns a view of the client adding a prefix to the metric names
+func (s *StdoutClient) WithPrefix(sub string) Statsd {
+	return newChildClient(s, sub, nil)
+}
+
+// WithTags returns a view of the client adding tags to all the metrics
+func (s *StdoutClient) WithTags(tags ...string) Statsd {
+	return newChildClient(s, "", tags)
+}
+
+// WithPrefix returns a view of the same parent, adding a prefix after the prefix of this view
+// and keeping its tags
+func (c *ChildClient) WithPrefix(sub string) Statsd {
+	return newChildClient(c.parent, c.prefix+sub, c.tags)
+}
+
+// WithTags returns a view of the same parent, adding tags after the tags of this view
+// and keeping its prefix
+func (c *ChildClient) WithTags(tags ...string) Statsd {
+	return newChildClient(c.parent, c.prefix, c.withTags(tags))
+}
+
+// withTags returns the tags of the view followed by the given ones
+func (c *ChildClient) withTags(tags []string) []string {
+	if 0 == len(c.tags) {
+		return tags
+	}
+	if 0 == len(tags) {
+		return c.tags
+	}
+	ret := make([]string, 0, len(c.tags)+len(tags))
+	ret = append(ret, c.tags...)
+	return append(ret, tags...)
+}
+
+// CreateSocket does nothing, the connection of the parent is used
+func (c *ChildClient) CreateSocket() error {
+	return nil
+}
+
+// CreateTCPSocket does nothing, the connection of the parent is used
+func (c *ChildClient) CreateTCPSocket() error {
+	return nil
+}
+
+// Close does nothing, the parent must be closed instead
+func (c *ChildClient) Close() error {
+	return nil
+}
+
+// Incr - Increment a counter metric. Often used to note a particular event
+func (c *ChildClient) Incr(stat string, count int64, tags ...string) error {
+	return c.parent.Incr(c.prefix+stat, count, c.withTags(tags)...)
+}
+
+// Decr - Decrement a counter metric. Often used to note a particular event
+func (c *ChildClient) Decr(stat string, count int64, tags ...string) error {
+	return c.parent.Decr(c.prefix+stat, count, c.withTags(tags)...)
+}
+
+// Timing - Track a duration event
+// the time delta must be given in milliseconds
+func (c *ChildClient) Timing(stat string, delta int64, tags ...string) error {
+	return c.parent.Timing(c.prefix+stat, delta, c.withTags(tags)...)
+}
+
+// PrecisionTiming - Track a duration event
+// the time delta has to be a duration
+func (c *ChildClient) PrecisionTiming(stat string, delta time.Duration, tags ...string) error {
+	return c.parent.PrecisionTiming(c.prefix+stat, delta, c.withTags(tags)...)
+}
+
+// Gauge - Gauges are a constant data type. They are not subject to averaging,
+// and they don’t change unless you change them. That is, once you set a gauge value,
+// it will be a flat line on the graph until you change it again
+func (c *ChildClient) Gauge(stat string, value int64, tags ...string) error {
+	return c.parent.Gauge(c.prefix+stat, value, c.withTags(tags)...)
+}
+
+// GaugeDelta records a delta from the previous value (as int64)
+func (c *ChildClient) GaugeDelta(stat string, value int64, tags ...string) error {
+	return c.parent.GaugeDelta(c.prefix+stat, value, c.withTags(tags)...)
+}
+
+// FGauge is a Gauge working with float64 values
+func (c *ChildClient) FGauge(stat string, value float64, tags ...string) error {
+	return c.parent.FGauge(c.prefix+stat, value, c.withTags(tags)...)
+}
+
+// FGaugeDelta records a delta from the previous value (as float64)
+func (c *ChildClient) FGaugeDelta(stat string, value float64, tags ...string) error {
+	return c.parent.FGaugeDelta(c.prefix+stat, value, c.withTags(tags)...)
+}
+
+// Absolute - Send absolute-valued metric (not averaged/aggregated)
+func (c *ChildClient) Absolute(stat string, value int64, tags ...string) error {
+	return c.parent.Absolute(c.prefix+stat, value, c.withTags(tags)...)
+}
+
+// FAbsolute - Send absolute-valued floating point metric (not averaged/aggregated)
+func (c *ChildClient) FAbsolute(stat string, value float64, tags ...string) error {
+	return c.parent.FAbsolute(c.prefix+stat, value, c.withTags(tags)...)
+}
+
+// Total - Send a metric that is continously increasing, e.g. read operations since boot
+func (c *ChildClient) Total(stat string, value int64, tags ...string) error {
+	return c.parent.Total(c.prefix+stat, value, c.withTags(tags)...)
+}
+
+// Set - Send a value to be counted as unique per flush interval (e.g. unique users)
+func (c *ChildClient) Set(stat string, value string, tags ...string) error {
+	return c.parent.Set(c.prefix+stat, value, c.withTags(tags)...)
+}
+
+// Histogram - Send a value to be aggregated into a statistical distribution by the server (per host)
+func (c *ChildClient) Histogram(stat string, value float64, tags ...string) error {
+	return c.parent.Histogram(c.prefix+stat, value, c.withTags(tags)...)
+}
+
+// Distribution - Send a value to be aggregated into a statistical distribution
+// by the server (globally, across all hosts)
+func (c *ChildClient) Distribution(stat string, value float64, tags ...string) error {
+	return c.parent.Distribution(c.prefix+stat, value, c.withTags(tags)...)
+}
+
+// SendEvents - Sends stats from all the event objects, renamed with the prefix
+// and with the tags of the view (copies are sent, the events are not modified)
+func (c *ChildClient) SendEvents(events map[string]event.Event) error {
+	renamed := make(map[string]event.Event, len(events))
+	for k, e := range events {
+		renamed[k] = renamedCopy(e, c.prefix+e.Key(), c.withTags(e.GetTags()))
+	}
+	return c.parent.SendEvents(renamed)
+}
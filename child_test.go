@@ -0,0 +1,113 @@
+package statsd
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quipo/statsd/event"
+	"github.com/quipo/statsd/statsdtest"
+)
+
+var _ Statsd = (*ChildClient)(nil)
+
+func TestChildClient(t *testing.T) {
+	srv, err := statsdtest.NewServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	client := NewStatsdClient(srv.Addr, "test.")
+	if err = client.CreateSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	db := client.WithPrefix("db.").(*ChildClient).WithTags("env:prod")
+	db.Incr("query", 1, "table:users")
+	db.(*ChildClient).WithPrefix("pool.").Gauge("size", 5)
+	db.SendEvents(map[string]event.Event{"rows": &event.Total{Name: "rows", Value: 3, Tags: []string{"table:users"}}})
+	db.Close() // the connection is shared
+
+	client.WithTags("env:prod").Incr("req", 1)
+	if err = srv.WaitFor(4); nil != err {
+		t.Fatal(err)
+	}
+	srv.AssertCounter(t, "test.db.query", 1, "env:prod", "table:users")
+	srv.AssertGauge(t, "test.db.pool.size", 5, "env:prod")
+	srv.AssertCounter(t, "test.req", 1, "env:prod")
+	if m := srv.Find("test.db.rows", "t", "env:prod", "table:users"); 1 != len(m) {
+		t.Errorf("expected the total with the tags of the view and its own, got %v", m)
+	}
+}
+
+func TestBufferedChildClient(t *testing.T) {
+	client := &flakyClient{}
+	buffered := NewStatsdBuffer(time.Hour, client)
+	buffered.Verbose = false
+
+	api := buffered.WithPrefix("api.")
+	api.Incr("req", 1)
+	api.Incr("req", 2)
+	buffered.WithTags("env:prod").Incr("req", 1)
+	if err := buffered.Close(); nil != err {
+		t.Fatal(err)
+	}
+	lines := strings.Join(client.lines, "\n")
+	if !strings.Contains(lines, "api.req:3|c") || !strings.Contains(lines, "req:1|c|#env:prod") {
+		t.Errorf("unexpected metrics: %v", client.lines)
+	}
+}
+
+func TestStdoutChildClient(t *testing.T) {
+	f, err := ioutil.TempFile("", "statsd")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	client := NewStdoutClient("", "test.")
+	client.FD = f
+	client.WithPrefix("cache.").(*ChildClient).WithTags("region:eu").Incr("hits", 1)
+
+	out, err := ioutil.ReadFile(f.Name())
+	if nil != err {
+		t.Fatal(err)
+	}
+	if expected := "test.cache.hits:1|c|#region:eu"; expected != strings.TrimSpace(string(out)) {
+		t.Errorf("Expected: %s, Actual: %s", expected, out)
+	}
+}
+
+func TestChildClientsShareEvents(t *testing.T) {
+	a, b := &flakyClient{}, &flakyClient{}
+	multi := NewMultiClient(newChildClient(a, "a.", nil), newChildClient(b, "b.", []string{"env:prod"}))
+	inc := &event.Increment{Name: "req", Value: 1}
+	custom := &customEvent{name: "custom", value: 4}
+	if err := multi.SendEvents(map[string]event.Event{"req": inc, "custom": custom}); nil != err {
+		t.Fatal(err)
+	}
+
+	sort.Strings(a.lines)
+	sort.Strings(b.lines)
+	for _, tt := range []struct {
+		expected []string
+		actual   []string
+	}{
+		{[]string{"a.custom:4|c", "a.req:1|c"}, a.lines},
+		{[]string{"b.custom:4|c|#env:prod", "b.req:1|c|#env:prod"}, b.lines},
+	} {
+		if !reflect.DeepEqual(tt.expected, tt.actual) {
+			t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", tt.expected, tt.expected, tt.actual, tt.actual)
+		}
+	}
+	if "req" != inc.Name || nil != inc.Tags || "custom" != custom.name || nil != custom.tags {
+		t.Errorf("the events were modified: %v %v", inc, custom)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/quipo/statsd/event"
@@ -33,6 +35,9 @@ var Hostname string
 
 var errNotConnected = fmt.Errorf("cannot send stats, not connected to StatsD server")
 
+// defaultDialTimeout is the timeout for connecting to the StatsD server
+const defaultDialTimeout = 5 * time.Second
+
 // errors
 var (
 	ErrInvalidCount      = errors.New("count is less than 0")
@@ -48,23 +53,67 @@ func init() {
 type socketType string
 
 const (
-	udpSocket socketType = "udp"
-	tcpSocket socketType = "tcp"
+	udpSocket      socketType = "udp"
+	tcpSocket      socketType = "tcp"
+	unixSocket     socketType = "unix"
+	unixgramSocket socketType = "unixgram"
 )
 
+// isStream returns true for connection-oriented sockets, where each metric
+// must be terminated by a newline (datagrams are delimited by the packet itself)
+func (t socketType) isStream() bool {
+	return t == tcpSocket || t == unixSocket
+}
+
 // StatsdClient is a client library to send events to StatsD
 type StatsdClient struct {
+	stats    ClientStats // accessed atomically, first for 64-bit alignment
 	conn     net.Conn
 	addr     string
 	prefix   string
 	sockType socketType
 	Logger   Logger
+	// Reconnect, if set, re-establishes TCP and Unix stream connections
+	// in the background after a write fails
+	Reconnect *ReconnectPolicy
+	// ResolveInterval, if set before CreateSocket, is how often the hostname
+	// of a UDP address is resolved again, to follow the changes of its IP address
+	ResolveInterval time.Duration
+	// CoalesceInterval, if set before creating the socket, makes the metric functions queue
+	// their lines and send them together in packets of up to UDPPayloadSize bytes, when
+	// the next line doesn't fit or at this interval, whichever comes first (see Flush)
+	CoalesceInterval time.Duration
+	// OnError, if set, receives the errors happening in the background (lost connections,
+	// failures reconnecting, resolving the address again or flushing the coalesced lines)
+	// instead of the Logger. The errors of the metric functions are returned as usual.
+	OnError ErrorHandler
+
+	// per-client overrides of UDPPayloadSize, Hostname and defaultDialTimeout
+	payloadSize int
+	hostname    string
+	dialTimeout time.Duration
+
+	mu           sync.RWMutex // guards conn, which is replaced on reconnection or re-resolution
+	done         chan struct{}
+	resolveDone  chan struct{}
+	reconnecting bool
+	closed       bool
+
+	names templates // expanded metric names
+
+	telemetry         *telemetry // if set, the metrics about the client itself are sent in the background
+	telemetryInterval time.Duration
+	telemetryDone     chan struct{}
+
+	pendingMu    sync.Mutex // guards pending
+	pending      []byte     // lines queued when coalescing
+	coalesceDone chan struct{}
 }
 
 // NewStatsdClient - Factory
 func NewStatsdClient(addr string, prefix string) *StatsdClient {
-	// allow %HOST% in the prefix string
-	prefix = strings.Replace(prefix, "%HOST%", Hostname, 1)
+	// allow %HOST% and the other placeholders in the prefix string
+	prefix = expandTemplate(prefix, Hostname)
 	return &StatsdClient{
 		addr:   addr,
 		prefix: prefix,
@@ -77,46 +126,128 @@ func (c *StatsdClient) String() string {
 	return c.addr
 }
 
+// expand returns a metric name with its placeholders replaced
+func (c *StatsdClient) expand(name string) string {
+	return c.names.expand(name, c.host())
+}
+
+// host returns the hostname replacing %HOST% in the metric names
+func (c *StatsdClient) host() string {
+	if "" != c.hostname {
+		return c.hostname
+	}
+	return Hostname
+}
+
+// maxPayload returns the maximum number of bytes to send at one go
+func (c *StatsdClient) maxPayload() int {
+	if c.payloadSize > 0 {
+		return c.payloadSize
+	}
+	return UDPPayloadSize
+}
+
+// timeout returns the timeout for connecting to the StatsD server
+func (c *StatsdClient) timeout() time.Duration {
+	if c.dialTimeout > 0 {
+		return c.dialTimeout
+	}
+	return defaultDialTimeout
+}
+
 // CreateSocket creates a UDP connection to a StatsD server
 func (c *StatsdClient) CreateSocket() error {
-	conn, err := net.DialTimeout(string(udpSocket), c.addr, 5*time.Second)
-	if err != nil {
-		return err
-	}
-	c.conn = conn
-	c.sockType = udpSocket
-	return nil
+	return c.dial(udpSocket)
 }
 
 // CreateTCPSocket creates a TCP connection to a StatsD server
 func (c *StatsdClient) CreateTCPSocket() error {
-	conn, err := net.DialTimeout(string(tcpSocket), c.addr, 5*time.Second)
+	return c.dial(tcpSocket)
+}
+
+// CreateUnixSocket creates a connection to a StatsD server listening on
+// a Unix stream socket (the address is the path of the socket)
+func (c *StatsdClient) CreateUnixSocket() error {
+	return c.dial(unixSocket)
+}
+
+// CreateUnixgramSocket creates a connection to a StatsD server listening on
+// a Unix datagram socket (the address is the path of the socket).
+// Like UDP, SendEvents packs the events into packets of UDPPayloadSize bytes
+func (c *StatsdClient) CreateUnixgramSocket() error {
+	return c.dial(unixgramSocket)
+}
+
+func (c *StatsdClient) dial(sockType socketType) error {
+	conn, err := net.DialTimeout(string(sockType), c.addr, c.timeout())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopReconnect()
+	c.stopResolve()
+	c.stopCoalesce()
+	c.stopTelemetry()
+	c.sockType = sockType
+	c.closed = false
+	if c.CoalesceInterval > 0 {
+		c.startCoalesce(c.CoalesceInterval)
+	}
+	if nil != c.telemetry {
+		c.startTelemetry()
+	}
+	if sockType == udpSocket && c.ResolveInterval > 0 {
+		// also retries if the first lookup failed
+		c.startResolve(c.ResolveInterval)
+	}
 	if err != nil {
+		// keep trying in the background, if the server is just not up yet
+		if nil != c.Reconnect && sockType.isStream() {
+			c.startReconnect(c.Reconnect)
+		}
 		return err
 	}
+	if nil != c.conn {
+		// connecting again, e.g. after a failed health check: don't leak the previous connection
+		c.conn.Close()
+	}
 	c.conn = conn
-	c.sockType = tcpSocket
 	return nil
 }
 
-// Close the UDP connection
+// Close the connection
 func (c *StatsdClient) Close() error {
+	c.mu.Lock()
+	c.stopCoalesce()
+	c.mu.Unlock()
+	// send the lines still queued, if coalescing
+	if err := c.Flush(); nil != err {
+		handleError(c.OnError, c.Logger, &Error{Kind: ErrorFlush, Err: err}, "Error flushing stats", err.Error())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopReconnect()
+	c.stopResolve()
+	c.stopTelemetry()
+	c.closed = true
 	if nil == c.conn {
 		return nil
 	}
-	return c.conn.Close()
+	conn := c.conn
+	c.conn = nil
+	return conn.Close()
 }
 
 // See statsd data types here: http://statsd.readthedocs.org/en/latest/types.html
 // or also https://github.com/b/statsd_spec
 
 // Incr - Increment a counter metric. Often used to note a particular event
-func (c *StatsdClient) Incr(stat string, count int64) error {
-	return c.IncrWithSampling(stat, count, 1)
+func (c *StatsdClient) Incr(stat string, count int64, tags ...string) error {
+	return c.IncrWithSampling(stat, count, 1, tags...)
 }
 
 // IncrWithSampling - Increment a counter metric with sampling between 0 and 1
-func (c *StatsdClient) IncrWithSampling(stat string, count int64, sampleRate float32) error {
+func (c *StatsdClient) IncrWithSampling(stat string, count int64, sampleRate float32, tags ...string) error {
 	if err := checkSampleRate(sampleRate); err != nil {
 		return err
 	}
@@ -129,16 +260,16 @@ func (c *StatsdClient) IncrWithSampling(stat string, count int64, sampleRate flo
 		return err
 	}
 
-	return c.send(stat, "%d|c", count, sampleRate)
+	return c.send(stat, "%d|c", count, sampleRate, tags)
 }
 
 // Decr - Decrement a counter metric. Often used to note a particular event
-func (c *StatsdClient) Decr(stat string, count int64) error {
-	return c.DecrWithSampling(stat, count, 1)
+func (c *StatsdClient) Decr(stat string, count int64, tags ...string) error {
+	return c.DecrWithSampling(stat, count, 1, tags...)
 }
 
 // DecrWithSampling - Decrement a counter metric with sampling between 0 and 1
-func (c *StatsdClient) DecrWithSampling(stat string, count int64, sampleRate float32) error {
+func (c *StatsdClient) DecrWithSampling(stat string, count int64, sampleRate float32, tags ...string) error {
 	if err := checkSampleRate(sampleRate); err != nil {
 		return err
 	}
@@ -151,17 +282,17 @@ func (c *StatsdClient) DecrWithSampling(stat string, count int64, sampleRate flo
 		return err
 	}
 
-	return c.send(stat, "%d|c", -count, sampleRate)
+	return c.send(stat, "%d|c", -count, sampleRate, tags)
 }
 
 // Timing - Track a duration event
 // the time delta must be given in milliseconds
-func (c *StatsdClient) Timing(stat string, delta int64) error {
-	return c.TimingWithSampling(stat, delta, 1)
+func (c *StatsdClient) Timing(stat string, delta int64, tags ...string) error {
+	return c.TimingWithSampling(stat, delta, 1, tags...)
 }
 
 // TimingWithSampling - Track a duration event
-func (c *StatsdClient) TimingWithSampling(stat string, delta int64, sampleRate float32) error {
+func (c *StatsdClient) TimingWithSampling(stat string, delta int64, sampleRate float32, tags ...string) error {
 	if err := checkSampleRate(sampleRate); err != nil {
 		return err
 	}
@@ -170,13 +301,13 @@ func (c *StatsdClient) TimingWithSampling(stat string, delta int64, sampleRate f
 		return nil // ignore this call
 	}
 
-	return c.send(stat, "%d|ms", delta, sampleRate)
+	return c.send(stat, "%d|ms", delta, sampleRate, tags)
 }
 
 // PrecisionTiming - Track a duration event
 // the time delta has to be a duration
-func (c *StatsdClient) PrecisionTiming(stat string, delta time.Duration) error {
-	return c.send(stat, "%.6f|ms", float64(delta)/float64(time.Millisecond), 1)
+func (c *StatsdClient) PrecisionTiming(stat string, delta time.Duration, tags ...string) error {
+	return c.send(stat, "%.6f|ms", float64(delta)/float64(time.Millisecond), 1, tags)
 }
 
 // Gauge - Gauges are a constant data type. They are not subject to averaging,
@@ -185,12 +316,12 @@ func (c *StatsdClient) PrecisionTiming(stat string, delta time.Duration) error {
 // delta to be true, that specifies that the gauge should be updated, not set. Due to the
 // underlying protocol, you can't explicitly set a gauge to a negative number without
 // first setting it to zero.
-func (c *StatsdClient) Gauge(stat string, value int64) error {
-	return c.GaugeWithSampling(stat, value, 1)
+func (c *StatsdClient) Gauge(stat string, value int64, tags ...string) error {
+	return c.GaugeWithSampling(stat, value, 1, tags...)
 }
 
 // GaugeWithSampling - Gauges are a constant data type.
-func (c *StatsdClient) GaugeWithSampling(stat string, value int64, sampleRate float32) error {
+func (c *StatsdClient) GaugeWithSampling(stat string, value int64, sampleRate float32, tags ...string) error {
 	if err := checkSampleRate(sampleRate); err != nil {
 		return err
 	}
@@ -200,31 +331,31 @@ func (c *StatsdClient) GaugeWithSampling(stat string, value int64, sampleRate fl
 	}
 
 	if value < 0 {
-		err := c.send(stat, "%d|g", 0, 1)
+		err := c.send(stat, "%d|g", 0, 1, tags)
 		if nil != err {
 			return err
 		}
 	}
 
-	return c.send(stat, "%d|g", value, sampleRate)
+	return c.send(stat, "%d|g", value, sampleRate, tags)
 }
 
 // GaugeDelta -- Send a change for a gauge
-func (c *StatsdClient) GaugeDelta(stat string, value int64) error {
+func (c *StatsdClient) GaugeDelta(stat string, value int64, tags ...string) error {
 	// Gauge Deltas are always sent with a leading '+' or '-'. The '-' takes care of itself but the '+' must added by hand
 	if value < 0 {
-		return c.send(stat, "%d|g", value, 1)
+		return c.send(stat, "%d|g", value, 1, tags)
 	}
-	return c.send(stat, "+%d|g", value, 1)
+	return c.send(stat, "+%d|g", value, 1, tags)
 }
 
 // FGauge -- Send a floating point value for a gauge
-func (c *StatsdClient) FGauge(stat string, value float64) error {
-	return c.FGaugeWithSampling(stat, value, 1)
+func (c *StatsdClient) FGauge(stat string, value float64, tags ...string) error {
+	return c.FGaugeWithSampling(stat, value, 1, tags...)
 }
 
 // FGaugeWithSampling - Gauges are a constant data type.
-func (c *StatsdClient) FGaugeWithSampling(stat string, value float64, sampleRate float32) error {
+func (c *StatsdClient) FGaugeWithSampling(stat string, value float64, sampleRate float32, tags ...string) error {
 	if err := checkSampleRate(sampleRate); err != nil {
 		return err
 	}
@@ -234,68 +365,104 @@ func (c *StatsdClient) FGaugeWithSampling(stat string, value float64, sampleRate
 	}
 
 	if value < 0 {
-		err := c.send(stat, "%d|g", 0, 1)
+		err := c.send(stat, "%d|g", 0, 1, tags)
 		if nil != err {
 			return err
 		}
 	}
 
-	return c.send(stat, "%g|g", value, sampleRate)
+	return c.send(stat, "%g|g", value, sampleRate, tags)
 }
 
 // FGaugeDelta -- Send a floating point change for a gauge
-func (c *StatsdClient) FGaugeDelta(stat string, value float64) error {
+func (c *StatsdClient) FGaugeDelta(stat string, value float64, tags ...string) error {
 	if value < 0 {
-		return c.send(stat, "%g|g", value, 1)
+		return c.send(stat, "%g|g", value, 1, tags)
 	}
-	return c.send(stat, "+%g|g", value, 1)
+	return c.send(stat, "+%g|g", value, 1, tags)
 }
 
 // Absolute - Send absolute-valued metric (not averaged/aggregated)
-func (c *StatsdClient) Absolute(stat string, value int64) error {
-	return c.send(stat, "%d|a", value, 1)
+func (c *StatsdClient) Absolute(stat string, value int64, tags ...string) error {
+	return c.send(stat, "%d|a", value, 1, tags)
 }
 
 // FAbsolute - Send absolute-valued floating point metric (not averaged/aggregated)
-func (c *StatsdClient) FAbsolute(stat string, value float64) error {
-	return c.send(stat, "%g|a", value, 1)
+func (c *StatsdClient) FAbsolute(stat string, value float64, tags ...string) error {
+	return c.send(stat, "%g|a", value, 1, tags)
 }
 
 // Total - Send a metric that is continously increasing, e.g. read operations since boot
-func (c *StatsdClient) Total(stat string, value int64) error {
-	return c.send(stat, "%d|t", value, 1)
+func (c *StatsdClient) Total(stat string, value int64, tags ...string) error {
+	return c.send(stat, "%d|t", value, 1, tags)
 }
 
-// write a UDP packet with the statsd event
-func (c *StatsdClient) send(stat string, format string, value interface{}, sampleRate float32) error {
-	if c.conn == nil {
-		return errNotConnected
-	}
+// Set - Count the unique values seen for a metric (e.g. unique users per interval)
+func (c *StatsdClient) Set(stat string, value string, tags ...string) error {
+	return c.send(stat, "%s|s", value, 1, tags)
+}
+
+// Histogram - Send a value to be aggregated into a statistical distribution by the server (per host)
+func (c *StatsdClient) Histogram(stat string, value float64, tags ...string) error {
+	return c.send(stat, "%g|h", value, 1, tags)
+}
 
-	stat = strings.Replace(stat, "%HOST%", Hostname, 1)
+// Distribution - Send a value to be aggregated into a statistical distribution
+// by the server (globally, across all hosts)
+func (c *StatsdClient) Distribution(stat string, value float64, tags ...string) error {
+	return c.send(stat, "%g|d", value, 1, tags)
+}
+
+// write a UDP packet with the statsd event
+func (c *StatsdClient) send(stat string, format string, value interface{}, sampleRate float32, tags []string) error {
+	stat = c.expand(stat)
 	metricString := c.prefix + stat + ":" + fmt.Sprintf(format, value)
 
 	if sampleRate != 1 {
 		metricString = fmt.Sprintf("%s|@%f", metricString, sampleRate)
 	}
 
-	// if sending tcp append a newline
-	if c.sockType == tcpSocket {
-		metricString += "\n"
+	// DogStatsD-style tags always come last
+	if len(tags) > 0 {
+		metricString += "|#" + strings.Join(tags, ",")
 	}
 
-	_, err := fmt.Fprint(c.conn, metricString)
-	return err
+	return c.writeLine(metricString)
 }
 
-// SendEvent - Sends stats from an event object
-func (c *StatsdClient) SendEvent(e event.Event) error {
-	if c.conn == nil {
+// write the payload to the current connection. The lock is only held to get the connection,
+// so a stalled server doesn't block Close or the reconnections: when the connection is replaced
+// or closed, the writes in flight fail. The writes on stream sockets time out after the dial timeout.
+func (c *StatsdClient) write(payload []byte) error {
+	c.mu.RLock()
+	conn := c.conn
+	stream := c.sockType.isStream()
+	c.mu.RUnlock()
+	if nil == conn {
+		atomic.AddUint64(&c.stats.WriteErrors, 1)
 		return errNotConnected
 	}
+	if stream {
+		conn.SetWriteDeadline(time.Now().Add(c.timeout()))
+	}
+	n, err := conn.Write(payload)
+	if nil != err {
+		atomic.AddUint64(&c.stats.WriteErrors, 1)
+		c.writeFailed(conn, err)
+		return err
+	}
+	atomic.AddUint64(&c.stats.PacketsSent, 1)
+	atomic.AddUint64(&c.stats.BytesWritten, uint64(n))
+	return nil
+}
+
+// SendEvent - Sends stats from an event object
+func (c *StatsdClient) SendEvent(e event.Event) error {
+	key := e.Key()
+	name := c.expand(key)
 	for _, stat := range e.Stats() {
-		//fmt.Printf("SENDING EVENT %s%s\n", c.prefix, strings.Replace(stat, "%HOST%", Hostname, 1))
-		_, err := fmt.Fprintf(c.conn, "%s%s", c.prefix, strings.Replace(stat, "%HOST%", Hostname, 1))
+		//fmt.Printf("SENDING EVENT %s%s\n", c.prefix, expandLine(stat, key, name))
+		err := c.writeLine(c.prefix + expandLine(stat, key, name))
 		if nil != err {
 			return err
 		}
@@ -304,45 +471,10 @@ func (c *StatsdClient) SendEvent(e event.Event) error {
 }
 
 // SendEvents - Sends stats from all the event objects.
-// Tries to bundle many together into one fmt.Fprintf based on UDPPayloadSize.
+// Tries to bundle many together into one write based on UDPPayloadSize
+// (or the payload size set with WithPayloadSize).
 func (c *StatsdClient) SendEvents(events map[string]event.Event) error {
-	if c.conn == nil {
-		return errNotConnected
-	}
-
-	var n int
-	var stats = make([]string, 0)
-
-	for _, e := range events {
-		for _, stat := range e.Stats() {
-
-			stat = c.prefix + strings.Replace(stat, "%HOST%", Hostname, 1)
-			_n := n + len(stat) + 1
-
-			if _n > UDPPayloadSize {
-				// with this last event, the UDP payload would be too big
-				if _, err := fmt.Fprintf(c.conn, strings.Join(stats, "\n")+"\n"); err != nil {
-					return err
-				}
-				// reset payload after flushing, and add the last event
-				stats = []string{stat}
-				n = len(stat)
-				continue
-			}
-
-			// can fit more into the current payload
-			n = _n
-			stats = append(stats, stat)
-		}
-	}
-
-	if len(stats) != 0 {
-		if _, err := fmt.Fprintf(c.conn, strings.Join(stats, "\n")+"\n"); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return packEvents(events, c.prefix, c.expand, c.maxPayload(), c.write)
 }
 
 func checkCount(c int64) error {
@@ -1,11 +1,14 @@
 package statsd
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"net"
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"sort"
@@ -820,3 +823,113 @@ func getFreePort() int {
 	defer l.Close()
 	return l.Addr().(*net.TCPAddr).Port
 }
+
+func TestSendWithTags(t *testing.T) {
+	c := NewStatsdClient("127.0.0.1:1201", "test.")
+	mock := &MockNetConn{}
+	c.conn = mock
+
+	err := c.Incr("req", 1, "env:prod", "route:/api")
+	if nil != err {
+		t.Error(err)
+	}
+	// sampled out about once every million calls
+	for i := 0; i < 10 && !strings.Contains(mock.buf.String(), "|@"); i++ {
+		err = c.IncrWithSampling("req", 1, 0.999999, "env:prod")
+		if nil != err {
+			t.Error(err)
+		}
+	}
+
+	actual := strings.Split(strings.TrimSpace(mock.buf.String()), "\n")
+	if 2 != len(actual) {
+		t.Fatalf("unexpected metrics: %v", actual)
+	}
+	if actual[0] != "test.req:1|c|#env:prod,route:/api" {
+		t.Errorf("unexpected metric with tags: %s", actual[0])
+	}
+	// the sample rate comes before the tags
+	if actual[1] != "test.req:1|c|@0.999999|#env:prod" {
+		t.Errorf("unexpected sampled metric with tags: %s", actual[1])
+	}
+}
+
+func TestUnixSockets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statsd")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// datagram: each metric in its own packet, SendEvents packs them up to UDPPayloadSize
+	path := filepath.Join(dir, "statsd.dgram")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := NewStatsdClient(path, "test.")
+	if err = client.CreateUnixgramSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	client.Incr("a", 1)
+	client.SendEvents(map[string]event.Event{
+		"b": &event.Increment{Name: "b", Value: 2},
+		"c": &event.Gauge{Name: "c", Value: 3},
+	})
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "test.a:1|c" != string(buf[:n]) {
+		t.Errorf("unexpected datagram: %q", string(buf[:n]))
+	}
+	n, err = conn.Read(buf)
+	if nil != err {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(buf[:n])), "\n")
+	sort.Strings(lines)
+	if expected := []string{"test.b:2|c", "test.c:3|g"}; !reflect.DeepEqual(expected, lines) {
+		t.Errorf("unexpected datagram: Expected: %v, Actual: %v", expected, lines)
+	}
+
+	// stream: each metric is terminated by a newline
+	path = filepath.Join(dir, "statsd.sock")
+	ln, err := net.Listen("unix", path)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	ch := make(chan []string)
+	go func() {
+		var lines []string
+		if c, err := ln.Accept(); nil == err {
+			scanner := bufio.NewScanner(c)
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+		}
+		ch <- lines
+	}()
+
+	client = NewStatsdClient(path, "test.")
+	if err = client.CreateUnixSocket(); nil != err {
+		t.Fatal(err)
+	}
+	client.Incr("a", 1)
+	client.SendEvent(&event.Gauge{Name: "c", Value: 3})
+	client.Close()
+
+	received := <-ch
+	if expected := []string{"test.a:1|c", "test.c:3|g"}; !reflect.DeepEqual(expected, received) {
+		t.Errorf("unexpected lines: Expected: %v, Actual: %v", expected, received)
+	}
+}
@@ -0,0 +1,73 @@
+package statsd
+
+import "time"
+
+// writeLine writes a single metric line or, if CoalesceInterval is set, queues it
+// to be sent with the next ones in a packet of up to UDPPayloadSize bytes
+func (c *StatsdClient) writeLine(line string) error {
+	if c.CoalesceInterval <= 0 {
+		// if sending over a stream socket (tcp or unix) append a newline
+		if c.sockType.isStream() {
+			line += "\n"
+		}
+		return c.write([]byte(line))
+	}
+
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	var err error
+	if len(c.pending) > 0 && len(c.pending)+len(line)+1 > c.maxPayload() {
+		// with this line, the packet would be too big
+		err = c.flushPending()
+	}
+	c.pending = append(c.pending, line...)
+	c.pending = append(c.pending, '\n')
+	return err
+}
+
+// Flush sends the metric lines queued when coalescing, if any
+func (c *StatsdClient) Flush() error {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	return c.flushPending()
+}
+
+// flushPending sends the queued lines. Must be called with pendingMu held.
+func (c *StatsdClient) flushPending() error {
+	if 0 == len(c.pending) {
+		return nil
+	}
+	err := c.write(c.pending)
+	c.pending = c.pending[:0]
+	return err
+}
+
+// startCoalesce starts flushing the queued lines at the given interval.
+// Must be called with the lock held.
+func (c *StatsdClient) startCoalesce(interval time.Duration) {
+	c.coalesceDone = make(chan struct{})
+	go c.coalesceLoop(interval, c.coalesceDone)
+}
+
+// stopCoalesce stops flushing the queued lines, if running. Must be called with the lock held.
+func (c *StatsdClient) stopCoalesce() {
+	if nil != c.coalesceDone {
+		close(c.coalesceDone)
+		c.coalesceDone = nil
+	}
+}
+
+func (c *StatsdClient) coalesceLoop(interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.Flush(); nil != err {
+				handleError(c.OnError, c.Logger, &Error{Kind: ErrorFlush, Err: err}, "Error flushing stats", err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package statsd
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func readPacket(t *testing.T, conn *net.UDPConn) string {
+	t.Helper()
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestCoalescing(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// only flushed when the packet is full, or on Close
+	client, err := NewStatsdClientWithOptions(conn.LocalAddr().String(), "test.",
+		WithCoalescing(time.Hour),
+		WithPayloadSize(40),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, stat := range []string{"a", "b", "c", "d", "e"} {
+		if err = client.Incr(stat, 1); nil != err {
+			t.Error(err)
+		}
+	}
+	// 3 lines of 11 bytes fit into 40 bytes
+	if expected, actual := "test.a:1|c\ntest.b:1|c\ntest.c:1|c\n", readPacket(t, conn); expected != actual {
+		t.Errorf("unexpected packet: Expected: %q, Actual: %q", expected, actual)
+	}
+	client.Close()
+	if expected, actual := "test.d:1|c\ntest.e:1|c\n", readPacket(t, conn); expected != actual {
+		t.Errorf("unexpected packet: Expected: %q, Actual: %q", expected, actual)
+	}
+}
+
+func TestCoalescingInterval(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := NewStatsdClient(conn.LocalAddr().String(), "test.")
+	client.CoalesceInterval = 10 * time.Millisecond
+	if err = client.CreateSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	client.Incr("a", 1)
+	client.Gauge("b", 2)
+	// most likely in one packet, unless the interval elapsed in between
+	expected := "test.a:1|c\ntest.b:2|g\n"
+	actual := readPacket(t, conn)
+	if len(actual) < len(expected) {
+		actual += readPacket(t, conn)
+	}
+	if expected != actual {
+		t.Errorf("unexpected packets: Expected: %q, Actual: %q", expected, actual)
+	}
+}
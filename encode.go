@@ -0,0 +1,93 @@
+package statsd
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/quipo/statsd/event"
+)
+
+// maxPooledBuffer is the capacity above which a buffer is not put back in the pool,
+// so that a single huge flush does not pin memory forever
+const maxPooledBuffer = 64 * 1024
+
+// bufferPool recycles the buffers used to encode the events in SendEvents
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 1024)
+		return &buf
+	},
+}
+
+func getBuffer() *[]byte {
+	buf := bufferPool.Get().(*[]byte)
+	*buf = (*buf)[:0]
+	return buf
+}
+
+func putBuffer(buf *[]byte) {
+	if cap(*buf) <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
+}
+
+// appendEvent appends the newline-terminated lines of an event to buf, with the prefix,
+// expanding the placeholders in its name (unless expand is nil)
+func appendEvent(buf []byte, e event.Event, prefix string, expand func(string) string) []byte {
+	key := e.Key()
+	name := key
+	if nil != expand {
+		name = expand(key)
+	}
+	if a, ok := builtinAppender(e); ok && name == key {
+		return a.AppendStats(buf, prefix)
+	}
+	// custom events, or names with placeholders
+	for _, stat := range e.Stats() {
+		buf = append(buf, prefix...)
+		buf = append(buf, expandLine(stat, key, name)...)
+		buf = append(buf, '\n')
+	}
+	return buf
+}
+
+// builtinAppender returns the event as an event.StatsAppender if it's one of the built-in events.
+// The types embedding a built-in event also have AppendStats, which would ignore their own Stats().
+func builtinAppender(e event.Event) (event.StatsAppender, bool) {
+	switch e.(type) {
+	case *event.Absolute, *event.Distribution, *event.FAbsolute, *event.FGauge, *event.FGaugeDelta,
+		*event.Gauge, *event.GaugeDelta, *event.Histogram, *event.Increment, *event.PrecisionTiming,
+		*event.Set, *event.Timing, *event.Total:
+		a, ok := e.(event.StatsAppender)
+		return a, ok
+	}
+	return nil, false
+}
+
+// packEvents encodes the events and calls write with as many lines as fit
+// into payloadSize bytes at a time (a longer line is written on its own)
+func packEvents(events map[string]event.Event, prefix string, expand func(string) string, payloadSize int, write func([]byte) error) error {
+	lines, packet := getBuffer(), getBuffer()
+	defer putBuffer(lines)
+	defer putBuffer(packet)
+
+	for _, e := range events {
+		*lines = appendEvent((*lines)[:0], e, prefix, expand)
+		for l := *lines; len(l) > 0; {
+			n := bytes.IndexByte(l, '\n') + 1
+			if len(*packet)+n > payloadSize && len(*packet) > 0 {
+				// with this line, the payload would be too big
+				if err := write(*packet); nil != err {
+					return err
+				}
+				*packet = (*packet)[:0]
+			}
+			*packet = append(*packet, l[:n]...)
+			l = l[n:]
+		}
+	}
+	if len(*packet) > 0 {
+		return write(*packet)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package statsd
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/quipo/statsd/event"
+)
+
+// customEvent implements event.Event without embedding a built-in event,
+// so it only has Stats(), not event.StatsAppender
+type customEvent struct {
+	name  string
+	value int64
+	tags  []string
+}
+
+func (e *customEvent) Update(e2 event.Event) error {
+	e.value += e2.Payload().(int64)
+	return nil
+}
+func (e customEvent) Payload() interface{} {
+	return e.value
+}
+func (e customEvent) Stats() []string {
+	return []string{e.name + ":" + strconv.FormatInt(e.value, 10) + "|c"}
+}
+func (e customEvent) Key() string {
+	return e.name
+}
+func (e *customEvent) SetKey(key string) {
+	e.name = key
+}
+func (e customEvent) GetTags() []string {
+	return e.tags
+}
+func (e *customEvent) SetTags(tags []string) {
+	e.tags = tags
+}
+func (e customEvent) Type() int {
+	return 100
+}
+func (e customEvent) TypeString() string {
+	return "Custom"
+}
+func (e customEvent) String() string {
+	return e.name
+}
+
+// overridingEvent embeds a built-in event, but has its own Stats()
+type overridingEvent struct {
+	event.Increment
+}
+
+func (e overridingEvent) Stats() []string {
+	return []string{"OVERRIDDEN:1|c"}
+}
+
+func TestPackEvents(t *testing.T) {
+	events := map[string]event.Event{
+		"a":    &event.Increment{Name: "a", Value: 1},
+		"b":    &event.Gauge{Name: "b", Value: -2},
+		"host": &event.Increment{Name: "req.%HOST%", Value: 3},
+		"c":    &customEvent{name: "custom", value: 4},
+		"x":    &overridingEvent{event.Increment{Name: "x", Value: 2}},
+		"long": &event.Increment{Name: strings.Repeat("x", 50), Value: 5},
+	}
+	var packets []string
+	expand := func(name string) string {
+		return expandTemplate(name, "host1")
+	}
+	err := packEvents(events, "p.", expand, 30, func(payload []byte) error {
+		packets = append(packets, string(payload)) // copy, the buffer is reused
+		return nil
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for _, packet := range packets {
+		if len(packet) > 30 && strings.Count(packet, "\n") > 1 {
+			t.Errorf("packet too big: %q", packet)
+		}
+		if !strings.HasSuffix(packet, "\n") {
+			t.Errorf("the packet does not end with a newline: %q", packet)
+		}
+		lines = append(lines, strings.Split(strings.TrimSpace(packet), "\n")...)
+	}
+	sort.Strings(lines)
+	expected := []string{
+		"p.a:1|c",
+		"p.b:-2|g",
+		"p.b:0|g",
+		"p.custom:4|c",
+		"p.OVERRIDDEN:1|c",
+		"p.req.host1:3|c",
+		"p." + strings.Repeat("x", 50) + ":5|c",
+	}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(expected, lines) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, lines, lines)
+	}
+}
+
+func TestStdoutSendEvents(t *testing.T) {
+	f, err := ioutil.TempFile("", "statsd")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	client := NewStdoutClient("", "test.")
+	client.FD = f
+	events := make(map[string]event.Event)
+	for i := 0; i < 100; i++ { // more than UDPPayloadSize bytes
+		k := "metric" + strconv.Itoa(i)
+		events[k] = &event.Increment{Name: k, Value: 1}
+	}
+	if err = client.SendEvents(events); nil != err {
+		t.Fatal(err)
+	}
+
+	out, err := ioutil.ReadFile(f.Name())
+	if nil != err {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if 100 != len(lines) {
+		t.Errorf("wrong number of lines: Expected: 100, Actual: %d", len(lines))
+	}
+}
+
+func BenchmarkSendEvents(b *testing.B) {
+	client := NewStatsdClient("127.0.0.1:1201", "test.")
+	client.conn = &discardConn{}
+	events := make(map[string]event.Event)
+	for i := 0; i < 100; i++ {
+		k := "metric" + strconv.Itoa(i)
+		events[k] = &event.Increment{Name: k, Value: int64(i), Tags: []string{"env:prod"}}
+		events["t"+k] = event.NewPrecisionTiming(k, 1500000)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := client.SendEvents(events); nil != err {
+			b.Fatal(err)
+		}
+	}
+}
+
+// discardConn is a net.Conn discarding all the writes
+type discardConn struct {
+	MockNetConn
+}
+
+func (c *discardConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
@@ -0,0 +1,97 @@
+package statsd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/quipo/statsd/event"
+)
+
+// ErrorKind is the kind of an Error passed to an ErrorHandler
+type ErrorKind int
+
+// error kinds
+const (
+	// ErrorFlush is a failure sending the aggregated events (or the coalesced lines)
+	ErrorFlush ErrorKind = iota
+	// ErrorTypeConflict is an event which can't be aggregated with a previous one with the same key
+	ErrorTypeConflict
+	// ErrorConnection is a lost connection, or a failure reconnecting or resolving the address again
+	ErrorConnection
+)
+
+// String returns the name of the kind of error
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorFlush:
+		return "flush"
+	case ErrorTypeConflict:
+		return "type conflict"
+	case ErrorConnection:
+		return "connection"
+	}
+	return "unknown"
+}
+
+// Error is an error happening in the background, e.g. while the buffered client flushes,
+// passed to the ErrorHandler with the keys of the metrics affected, if known
+type Error struct {
+	Kind ErrorKind
+	Keys []string
+	Err  error
+}
+
+// Error returns the description of the error, with the keys affected
+func (e *Error) Error() string {
+	if 0 == len(e.Keys) {
+		return fmt.Sprintf("statsd %s error: %s", e.Kind, e.Err)
+	}
+	return fmt.Sprintf("statsd %s error: %s (keys: %s)", e.Kind, e.Err, strings.Join(e.Keys, ", "))
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorHandler receives the errors happening in the background, instead of the Logger.
+// It's called by the goroutine where the error happens (e.g. the collector of the buffered
+// client, or any goroutine sending metrics), so it must be safe for concurrent use and return quickly.
+type ErrorHandler func(err *Error)
+
+// ErrorChannel returns an ErrorHandler sending the errors to a channel.
+// It never blocks: the errors are dropped while the channel is full.
+func ErrorChannel(ch chan<- *Error) ErrorHandler {
+	return func(err *Error) {
+		select {
+		case ch <- err:
+		default:
+		}
+	}
+}
+
+// handleError passes the error to the handler if set, or else logs the message, if any
+func handleError(handler ErrorHandler, logger Logger, err *Error, msg ...interface{}) {
+	if nil != handler {
+		handler(err)
+		return
+	}
+	if len(msg) > 0 {
+		logger.Println(msg...)
+	}
+}
+
+// eventKeys returns the sorted, unique keys of the events
+func eventKeys(events map[string]event.Event) []string {
+	seen := make(map[string]bool, len(events))
+	keys := make([]string, 0, len(events))
+	for _, e := range events {
+		if k := e.Key(); !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,122 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/quipo/statsd/event"
+)
+
+// conflictingIncrement has the same key as an Increment, but a different type
+type conflictingIncrement struct {
+	event.Increment
+}
+
+func (e conflictingIncrement) Type() int {
+	return event.EventGauge
+}
+
+// waitError returns the first error of the given kind received on ch
+func waitError(t *testing.T, ch chan *Error, kind ErrorKind) *Error {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case err := <-ch:
+			if kind == err.Kind {
+				return err
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for a %s error", kind)
+		}
+	}
+}
+
+func TestBufferedErrorHandler(t *testing.T) {
+	errs := make(chan *Error, 10)
+	client := &flakyClient{down: true}
+	buffered, err := NewStatsdBufferWithOptions(time.Hour, client,
+		WithErrorHandler(ErrorChannel(errs)),
+		WithVerbose(false),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	buffered.Incr("a", 1)
+	buffered.SendEvents(map[string]event.Event{"a": &conflictingIncrement{event.Increment{Name: "a", Value: 1}}})
+	e := waitError(t, errs, ErrorTypeConflict)
+	if expected := []string{"a"}; !reflect.DeepEqual(expected, e.Keys) {
+		t.Errorf("wrong keys: Expected: %v, Actual: %v", expected, e.Keys)
+	}
+
+	// the flush errors are returned by Close
+	buffered.Gauge("b", 2)
+	err = buffered.Close()
+	if !errors.As(err, &e) || ErrorFlush != e.Kind {
+		t.Fatalf("expected a flush error, got %T %v", err, err)
+	}
+	if expected := []string{"a", "b"}; !reflect.DeepEqual(expected, e.Keys) {
+		t.Errorf("wrong keys: Expected: %v, Actual: %v", expected, e.Keys)
+	}
+	if "server unreachable" != errors.Unwrap(err).Error() {
+		t.Errorf("wrong error: %v", errors.Unwrap(err))
+	}
+
+	// and passed to the handler when flushing in the background
+	buffered, err = NewStatsdBufferWithOptions(time.Millisecond, client,
+		WithErrorHandler(ErrorChannel(errs)),
+		WithVerbose(false),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer buffered.Close()
+	buffered.Incr("c", 1)
+	if e = waitError(t, errs, ErrorFlush); !reflect.DeepEqual([]string{"c"}, e.Keys) {
+		t.Errorf("wrong keys: Expected: [c], Actual: %v", e.Keys)
+	}
+}
+
+func TestClientErrorHandler(t *testing.T) {
+	defer func(orig func(context.Context, string) ([]string, error)) {
+		lookupHost = orig
+	}(lookupHost)
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return nil, errors.New("lookup failed")
+	}
+
+	errs := make(chan *Error, 10)
+	client, err := NewStatsdClientWithOptions("localhost:8125", "test.",
+		WithResolveInterval(time.Millisecond),
+		WithErrorHandler(ErrorChannel(errs)),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if e := waitError(t, errs, ErrorConnection); "lookup failed" != e.Err.Error() {
+		t.Errorf("wrong error: %v", e)
+	}
+}
+
+func TestErrorChannel(t *testing.T) {
+	ch := make(chan *Error, 1)
+	handler := ErrorChannel(ch)
+	first := &Error{Kind: ErrorFlush, Err: errors.New("first")}
+	handler(first)
+	handler(&Error{Kind: ErrorFlush, Err: errors.New("second")}) // must not block
+	if e := <-ch; first != e {
+		t.Errorf("wrong error: %v", e)
+	}
+	if expected := "statsd flush error: first"; expected != first.Error() {
+		t.Errorf("wrong message: Expected: %s, Actual: %s", expected, first.Error())
+	}
+	first.Keys = []string{"a", "b"}
+	if expected := "statsd flush error: first (keys: a, b)"; expected != first.Error() {
+		t.Errorf("wrong message: Expected: %s, Actual: %s", expected, first.Error())
+	}
+}
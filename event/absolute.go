@@ -1,12 +1,16 @@
 package event
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+)
 
 // Absolute is a metric that is not averaged/aggregated.
 // We keep each value distinct and then we flush them all individually.
 type Absolute struct {
 	Name   string
 	Values []int64
+	Tags   []string
 }
 
 // Update the event with metrics coming from a new one of the same type and with the same key
@@ -25,13 +29,24 @@ func (e Absolute) Payload() interface{} {
 
 // Stats returns an array of StatsD events as they travel over UDP
 func (e Absolute) Stats() []string {
+	tags := tagSuffix(e.Tags)
 	ret := make([]string, 0, len(e.Values))
 	for _, v := range e.Values {
-		ret = append(ret, fmt.Sprintf("%s:%d|a", e.Name, v))
+		ret = append(ret, fmt.Sprintf("%s:%d|a%s", e.Name, v, tags))
 	}
 	return ret
 }
 
+// AppendStats appends the StatsD lines of Stats() to buf, without intermediate allocations
+func (e Absolute) AppendStats(buf []byte, prefix string) []byte {
+	for _, v := range e.Values {
+		buf = appendName(buf, prefix, e.Name, "")
+		buf = strconv.AppendInt(buf, v, 10)
+		buf = appendType(buf, "a", e.Tags)
+	}
+	return buf
+}
+
 // Key returns the name of this metric
 func (e Absolute) Key() string {
 	return e.Name
@@ -42,6 +57,16 @@ func (e *Absolute) SetKey(key string) {
 	e.Name = key
 }
 
+// GetTags returns the tags attached to this metric
+func (e Absolute) GetTags() []string {
+	return e.Tags
+}
+
+// SetTags sets the tags attached to this metric
+func (e *Absolute) SetTags(tags []string) {
+	e.Tags = tags
+}
+
 // Type returns an integer identifier for this type of metric
 func (e Absolute) Type() int {
 	return EventAbsolute
@@ -0,0 +1,88 @@
+package event
+
+import (
+	"math"
+	"strconv"
+)
+
+// StatsAppender is implemented by the events which can encode their StatsD lines
+// without allocating intermediate strings: AppendStats appends the same lines
+// returned by Stats(), each one preceded by the prefix and followed by a newline,
+// and returns the extended buffer.
+// The clients only use it for the built-in events: a type embedding one of them,
+// and overriding Stats(), is encoded with its own Stats().
+type StatsAppender interface {
+	AppendStats(buf []byte, prefix string) []byte
+}
+
+// compile-time assertion to verify default events implement the StatsAppender interface
+func _() {
+	var _ StatsAppender = (*Absolute)(nil)
+	var _ StatsAppender = (*FAbsolute)(nil)
+	var _ StatsAppender = (*Histogram)(nil)
+	var _ StatsAppender = (*Distribution)(nil)
+	var _ StatsAppender = (*Gauge)(nil)
+	var _ StatsAppender = (*FGauge)(nil)
+	var _ StatsAppender = (*GaugeDelta)(nil)
+	var _ StatsAppender = (*FGaugeDelta)(nil)
+	var _ StatsAppender = (*Increment)(nil)
+	var _ StatsAppender = (*PrecisionTiming)(nil)
+	var _ StatsAppender = (*Set)(nil)
+	var _ StatsAppender = (*Timing)(nil)
+	var _ StatsAppender = (*Total)(nil)
+}
+
+// appendName appends "<prefix><name><suffix>:"
+func appendName(buf []byte, prefix string, name string, suffix string) []byte {
+	buf = append(buf, prefix...)
+	buf = append(buf, name...)
+	buf = append(buf, suffix...)
+	return append(buf, ':')
+}
+
+// appendType appends "|<type>", the tags and the newline ending the line
+func appendType(buf []byte, typ string, tags []string) []byte {
+	buf = append(buf, '|')
+	buf = append(buf, typ...)
+	return appendTags(buf, tags)
+}
+
+// appendTags appends the DogStatsD-style tag section, if any, and the newline ending the line
+func appendTags(buf []byte, tags []string) []byte {
+	if len(tags) > 0 {
+		buf = append(buf, "|#"...)
+		for i, tag := range tags {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, tag...)
+		}
+	}
+	return append(buf, '\n')
+}
+
+// appendFloat appends a value formatted like fmt's %g
+func appendFloat(buf []byte, v float64) []byte {
+	return strconv.AppendFloat(buf, v, 'g', -1, 64)
+}
+
+// appendSignedInt appends a value formatted like fmt's %+d
+func appendSignedInt(buf []byte, v int64) []byte {
+	if v >= 0 {
+		buf = append(buf, '+')
+	}
+	return strconv.AppendInt(buf, v, 10)
+}
+
+// appendSignedFloat appends a value formatted like fmt's %+g
+func appendSignedFloat(buf []byte, v float64) []byte {
+	if !math.Signbit(v) {
+		buf = append(buf, '+')
+	}
+	return appendFloat(buf, v)
+}
+
+// appendMs appends a duration in milliseconds, formatted like fmt's %.6f
+func appendMs(buf []byte, ms float64) []byte {
+	return strconv.AppendFloat(buf, ms, 'f', 6, 64)
+}
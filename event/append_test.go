@@ -0,0 +1,76 @@
+package event
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendStats(t *testing.T) {
+	timing := NewTiming("t", 10, "env:prod")
+	timing.Percentiles = []float64{50, 99.9}
+	timing.Update(NewTiming("t", 25))
+	ptiming := NewPrecisionTiming("pt", 1500*time.Microsecond)
+	ptiming.Percentiles = []float64{90}
+	ptiming.Update(NewPrecisionTiming("pt", 3*time.Millisecond))
+	hist := NewHistogram("h", 1.5, "a:b")
+	hist.Count = 4 // sampled
+
+	events := []Event{
+		&Increment{Name: "inc", Value: -3},
+		&Increment{Name: "inc", Value: 42, Tags: []string{"a:b", "c:d"}},
+		&Total{Name: "tot", Value: 1 << 40},
+		&Gauge{Name: "g", Value: 7},
+		&Gauge{Name: "g", Value: -7, Tags: []string{"x"}},
+		&GaugeDelta{Name: "gd", Value: 0},
+		&GaugeDelta{Name: "gd", Value: -5},
+		&FGauge{Name: "fg", Value: 1e21},
+		&FGauge{Name: "fg", Value: -0.000001},
+		&FGaugeDelta{Name: "fgd", Value: 2.5},
+		&FGaugeDelta{Name: "fgd", Value: math.Copysign(0, -1)},
+		&Absolute{Name: "abs", Values: []int64{1, -2, 3}},
+		&FAbsolute{Name: "fabs", Values: []float64{0.1, 1e-7}},
+		NewSet("s", "user2", "a:b"),
+		hist,
+		NewDistribution("d", 100),
+		timing,
+		ptiming,
+	}
+	events[13].Update(NewSet("s", "user1"))
+
+	for _, e := range events {
+		var expected string
+		for _, stat := range e.Stats() {
+			expected += "prefix." + stat + "\n"
+		}
+		actual := string(e.(StatsAppender).AppendStats([]byte("previous\n"), "prefix."))
+		if !strings.HasPrefix(actual, "previous\n") {
+			t.Errorf("%s: the buffer was not appended to: %q", e.TypeString(), actual)
+			continue
+		}
+		if actual = actual[len("previous\n"):]; expected != actual {
+			t.Errorf("%s: Expected: %q, Actual: %q", e.TypeString(), expected, actual)
+		}
+	}
+}
+
+func TestAppendStatsAllocations(t *testing.T) {
+	buf := make([]byte, 0, 1024)
+	events := []StatsAppender{
+		&Increment{Name: "inc", Value: 3, Tags: []string{"a:b"}},
+		&Gauge{Name: "g", Value: -7},
+		&FGaugeDelta{Name: "fgd", Value: 2.5},
+		NewTiming("t", 10),
+		NewPrecisionTiming("pt", time.Millisecond),
+		NewHistogram("h", 1.5),
+	}
+	for _, e := range events {
+		allocs := testing.AllocsPerRun(100, func() {
+			buf = e.AppendStats(buf[:0], "prefix.")
+		})
+		if 0 != allocs {
+			t.Errorf("%T: expected no allocations, got %v", e, allocs)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package event
+
+import "fmt"
+
+// Distribution keeps the raw values of a metric over a certain interval, like a Histogram,
+// but the server computes the distribution globally across all hosts rather than per host
+type Distribution struct {
+	Name   string
+	Values []float64
+	Count  int64 // number of values seen, can be larger than len(Values) once sampled
+	Tags   []string
+}
+
+// NewDistribution is a factory for a Distribution event with a single value
+func NewDistribution(k string, value float64, tags ...string) *Distribution {
+	return &Distribution{Name: k, Values: []float64{value}, Count: 1, Tags: tags}
+}
+
+// Update the event with metrics coming from a new one of the same type and with the same key
+func (e *Distribution) Update(e2 Event) error {
+	if e.Type() != e2.Type() {
+		return fmt.Errorf("statsd event type conflict: %s vs %s ", e.String(), e2.String())
+	}
+	p := e2.Payload().(Distribution)
+	e.Values, e.Count = addSamples(e.Values, sampleCount(e.Values, e.Count), p.Values, sampleCount(p.Values, p.Count))
+	return nil
+}
+
+// Payload returns the aggregated value for this event
+func (e Distribution) Payload() interface{} {
+	return e
+}
+
+// Stats returns an array of StatsD events as they travel over UDP
+func (e Distribution) Stats() []string {
+	return sampleStats(e.Name, "d", e.Values, sampleCount(e.Values, e.Count), e.Tags)
+}
+
+// AppendStats appends the StatsD lines of Stats() to buf, without intermediate allocations
+func (e Distribution) AppendStats(buf []byte, prefix string) []byte {
+	return appendSamples(buf, prefix, e.Name, "d", e.Values, sampleCount(e.Values, e.Count), e.Tags)
+}
+
+// Key returns the name of this metric
+func (e Distribution) Key() string {
+	return e.Name
+}
+
+// SetKey sets the name of this metric
+func (e *Distribution) SetKey(key string) {
+	e.Name = key
+}
+
+// GetTags returns the tags attached to this metric
+func (e Distribution) GetTags() []string {
+	return e.Tags
+}
+
+// SetTags sets the tags attached to this metric
+func (e *Distribution) SetTags(tags []string) {
+	e.Tags = tags
+}
+
+// Type returns an integer identifier for this type of metric
+func (e Distribution) Type() int {
+	return EventDistribution
+}
+
+// TypeString returns a name for this type of metric
+func (e Distribution) TypeString() string {
+	return "Distribution"
+}
+
+// String returns a debug-friendly representation of this metric
+func (e Distribution) String() string {
+	return fmt.Sprintf("{Type: %s, Key: %s, Values: %v, Count: %d}", e.TypeString(), e.Name, e.Values, e.Count)
+}
@@ -7,6 +7,7 @@ import "fmt"
 type FAbsolute struct {
 	Name   string
 	Values []float64
+	Tags   []string
 }
 
 // Update the event with metrics coming from a new one of the same type and with the same key
@@ -25,13 +26,24 @@ func (e FAbsolute) Payload() interface{} {
 
 // Stats returns an array of StatsD events as they travel over UDP
 func (e FAbsolute) Stats() []string {
+	tags := tagSuffix(e.Tags)
 	ret := make([]string, 0, len(e.Values))
 	for _, v := range e.Values {
-		ret = append(ret, fmt.Sprintf("%s:%g|a", e.Name, v))
+		ret = append(ret, fmt.Sprintf("%s:%g|a%s", e.Name, v, tags))
 	}
 	return ret
 }
 
+// AppendStats appends the StatsD lines of Stats() to buf, without intermediate allocations
+func (e FAbsolute) AppendStats(buf []byte, prefix string) []byte {
+	for _, v := range e.Values {
+		buf = appendName(buf, prefix, e.Name, "")
+		buf = appendFloat(buf, v)
+		buf = appendType(buf, "a", e.Tags)
+	}
+	return buf
+}
+
 // Key returns the name of this metric
 func (e FAbsolute) Key() string {
 	return e.Name
@@ -42,6 +54,16 @@ func (e *FAbsolute) SetKey(key string) {
 	e.Name = key
 }
 
+// GetTags returns the tags attached to this metric
+func (e FAbsolute) GetTags() []string {
+	return e.Tags
+}
+
+// SetTags sets the tags attached to this metric
+func (e *FAbsolute) SetTags(tags []string) {
+	e.Tags = tags
+}
+
 // Type returns an integer identifier for this type of metric
 func (e FAbsolute) Type() int {
 	return EventFAbsolute
@@ -8,6 +8,7 @@ import "fmt"
 type FGauge struct {
 	Name  string
 	Value float64
+	Tags  []string
 }
 
 // Update the event with metrics coming from a new one of the same type and with the same key
@@ -26,16 +27,30 @@ func (e FGauge) Payload() interface{} {
 
 // Stats returns an array of StatsD events as they travel over UDP
 func (e FGauge) Stats() []string {
+	tags := tagSuffix(e.Tags)
 	if e.Value < 0 {
 		// because a leading '+' or '-' in the value of a gauge denotes a delta, to send
 		// a negative gauge value we first set the gauge absolutely to 0, then send the
 		// negative value as a delta from 0 (that's just how the spec works :-)
 		return []string{
-			fmt.Sprintf("%s:%d|g", e.Name, 0),
-			fmt.Sprintf("%s:%g|g", e.Name, e.Value),
+			fmt.Sprintf("%s:%d|g%s", e.Name, 0, tags),
+			fmt.Sprintf("%s:%g|g%s", e.Name, e.Value, tags),
 		}
 	}
-	return []string{fmt.Sprintf("%s:%g|g", e.Name, e.Value)}
+	return []string{fmt.Sprintf("%s:%g|g%s", e.Name, e.Value, tags)}
+}
+
+// AppendStats appends the StatsD lines of Stats() to buf, without intermediate allocations
+func (e FGauge) AppendStats(buf []byte, prefix string) []byte {
+	if e.Value < 0 {
+		// set the gauge to 0 first, then send the negative value as a delta (see Stats)
+		buf = appendName(buf, prefix, e.Name, "")
+		buf = append(buf, '0')
+		buf = appendType(buf, "g", e.Tags)
+	}
+	buf = appendName(buf, prefix, e.Name, "")
+	buf = appendFloat(buf, e.Value)
+	return appendType(buf, "g", e.Tags)
 }
 
 // Key returns the name of this metric
@@ -48,6 +63,16 @@ func (e *FGauge) SetKey(key string) {
 	e.Name = key
 }
 
+// GetTags returns the tags attached to this metric
+func (e FGauge) GetTags() []string {
+	return e.Tags
+}
+
+// SetTags sets the tags attached to this metric
+func (e *FGauge) SetTags(tags []string) {
+	e.Tags = tags
+}
+
 // Type returns an integer identifier for this type of metric
 func (e FGauge) Type() int {
 	return EventFGauge
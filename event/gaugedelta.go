@@ -8,6 +8,7 @@ import "fmt"
 type GaugeDelta struct {
 	Name  string
 	Value int64
+	Tags  []string
 }
 
 // Update the event with metrics coming from a new one of the same type and with the same key
@@ -26,7 +27,15 @@ func (e GaugeDelta) Payload() interface{} {
 
 // Stats returns an array of StatsD events as they travel over UDP
 func (e GaugeDelta) Stats() []string {
-	return []string{fmt.Sprintf("%s:%+d|g", e.Name, e.Value)}
+	tags := tagSuffix(e.Tags)
+	return []string{fmt.Sprintf("%s:%+d|g%s", e.Name, e.Value, tags)}
+}
+
+// AppendStats appends the StatsD lines of Stats() to buf, without intermediate allocations
+func (e GaugeDelta) AppendStats(buf []byte, prefix string) []byte {
+	buf = appendName(buf, prefix, e.Name, "")
+	buf = appendSignedInt(buf, e.Value)
+	return appendType(buf, "g", e.Tags)
 }
 
 // Key returns the name of this metric
@@ -39,6 +48,16 @@ func (e *GaugeDelta) SetKey(key string) {
 	e.Name = key
 }
 
+// GetTags returns the tags attached to this metric
+func (e GaugeDelta) GetTags() []string {
+	return e.Tags
+}
+
+// SetTags sets the tags attached to this metric
+func (e *GaugeDelta) SetTags(tags []string) {
+	e.Tags = tags
+}
+
 // Type returns an integer identifier for this type of metric
 func (e GaugeDelta) Type() int {
 	return EventGaugeDelta
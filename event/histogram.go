@@ -0,0 +1,144 @@
+package event
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+)
+
+// MaxSamples is the maximum number of raw values kept in memory by a Histogram
+// or Distribution event between flushes. Once the limit is reached, the values
+// are replaced using reservoir sampling, and the resulting sample rate is sent
+// along with the values so that the server can still compute the right count.
+var MaxSamples = 1000
+
+// Histogram keeps the raw values of a metric over a certain interval,
+// to let the server compute its own statistical distribution
+type Histogram struct {
+	Name   string
+	Values []float64
+	Count  int64 // number of values seen, can be larger than len(Values) once sampled
+	Tags   []string
+}
+
+// NewHistogram is a factory for a Histogram event with a single value
+func NewHistogram(k string, value float64, tags ...string) *Histogram {
+	return &Histogram{Name: k, Values: []float64{value}, Count: 1, Tags: tags}
+}
+
+// Update the event with metrics coming from a new one of the same type and with the same key
+func (e *Histogram) Update(e2 Event) error {
+	if e.Type() != e2.Type() {
+		return fmt.Errorf("statsd event type conflict: %s vs %s ", e.String(), e2.String())
+	}
+	p := e2.Payload().(Histogram)
+	e.Values, e.Count = addSamples(e.Values, sampleCount(e.Values, e.Count), p.Values, sampleCount(p.Values, p.Count))
+	return nil
+}
+
+// Payload returns the aggregated value for this event
+func (e Histogram) Payload() interface{} {
+	return e
+}
+
+// Stats returns an array of StatsD events as they travel over UDP
+func (e Histogram) Stats() []string {
+	return sampleStats(e.Name, "h", e.Values, sampleCount(e.Values, e.Count), e.Tags)
+}
+
+// AppendStats appends the StatsD lines of Stats() to buf, without intermediate allocations
+func (e Histogram) AppendStats(buf []byte, prefix string) []byte {
+	return appendSamples(buf, prefix, e.Name, "h", e.Values, sampleCount(e.Values, e.Count), e.Tags)
+}
+
+// Key returns the name of this metric
+func (e Histogram) Key() string {
+	return e.Name
+}
+
+// SetKey sets the name of this metric
+func (e *Histogram) SetKey(key string) {
+	e.Name = key
+}
+
+// GetTags returns the tags attached to this metric
+func (e Histogram) GetTags() []string {
+	return e.Tags
+}
+
+// SetTags sets the tags attached to this metric
+func (e *Histogram) SetTags(tags []string) {
+	e.Tags = tags
+}
+
+// Type returns an integer identifier for this type of metric
+func (e Histogram) Type() int {
+	return EventHistogram
+}
+
+// TypeString returns a name for this type of metric
+func (e Histogram) TypeString() string {
+	return "Histogram"
+}
+
+// String returns a debug-friendly representation of this metric
+func (e Histogram) String() string {
+	return fmt.Sprintf("{Type: %s, Key: %s, Values: %v, Count: %d}", e.TypeString(), e.Name, e.Values, e.Count)
+}
+
+// sampleCount returns the number of values seen, defaulting to the number
+// of values kept when the count was not set (e.g. events built as literals)
+func sampleCount(values []float64, count int64) int64 {
+	if count < int64(len(values)) {
+		return int64(len(values))
+	}
+	return count
+}
+
+// addSamples merges new values into the kept ones, using reservoir sampling
+// to bound the number of values kept to MaxSamples.
+// If the new values were sampled themselves, they are given the same weight
+// as the others: it's an approximation, but a good enough one.
+func addSamples(values []float64, count int64, newValues []float64, newCount int64) ([]float64, int64) {
+	for _, v := range newValues {
+		count++
+		if len(values) < MaxSamples {
+			values = append(values, v)
+			continue
+		}
+		if j := rand.Int63n(count); j < int64(len(values)) {
+			values[j] = v
+		}
+	}
+	return values, count + newCount - int64(len(newValues))
+}
+
+// sampleStats formats the kept values, adding the sample rate if some values were dropped
+func sampleStats(name string, typ string, values []float64, count int64, tags []string) []string {
+	suffix := tagSuffix(tags)
+	if count > int64(len(values)) {
+		suffix = fmt.Sprintf("|@%f", float64(len(values))/float64(count)) + suffix
+	}
+	ret := make([]string, 0, len(values))
+	for _, v := range values {
+		ret = append(ret, fmt.Sprintf("%s:%g|%s%s", name, v, typ, suffix))
+	}
+	return ret
+}
+
+// appendSamples appends the lines of sampleStats to buf
+func appendSamples(buf []byte, prefix string, name string, typ string, values []float64, count int64, tags []string) []byte {
+	sampled := count > int64(len(values))
+	for _, v := range values {
+		buf = appendName(buf, prefix, name, "")
+		buf = appendFloat(buf, v)
+		buf = append(buf, '|')
+		buf = append(buf, typ...)
+		if sampled {
+			buf = append(buf, "|@"...)
+			buf = strconv.AppendFloat(buf, float64(len(values))/float64(count), 'f', 6, 64)
+		}
+		buf = appendTags(buf, tags)
+	}
+	return buf
+}
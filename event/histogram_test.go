@@ -0,0 +1,70 @@
+package event
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistogramUpdate(t *testing.T) {
+	e1 := NewHistogram("test", 5)
+	e2 := NewHistogram("test", 3.5)
+	e3 := NewHistogram("test", 5)
+	err := e1.Update(e2)
+	if nil != err {
+		t.Error(err)
+	}
+	err = e1.Update(e3)
+	if nil != err {
+		t.Error(err)
+	}
+
+	expected := []string{"test:5|h", "test:3.5|h", "test:5|h"} // all the raw values are flushed
+	actual := e1.Stats()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, actual, actual)
+	}
+}
+
+func TestHistogramMaxSamples(t *testing.T) {
+	defer func(n int) { MaxSamples = n }(MaxSamples)
+	MaxSamples = 10
+
+	e1 := NewHistogram("test", 1, "env:prod")
+	for i := 0; i < 39; i++ {
+		if err := e1.Update(NewHistogram("test", float64(i))); nil != err {
+			t.Error(err)
+		}
+	}
+	if 40 != e1.Count {
+		t.Errorf("wrong number of values seen: expected 40, actual %d", e1.Count)
+	}
+
+	actual := e1.Stats()
+	if len(actual) != MaxSamples {
+		t.Fatalf("wrong number of values kept: expected %d, actual %d", MaxSamples, len(actual))
+	}
+	for _, stat := range actual {
+		if !strings.HasSuffix(stat, "|h|@0.250000|#env:prod") {
+			t.Errorf("expected the sample rate to be sent with sampled values: %s", stat)
+		}
+	}
+}
+
+func TestDistributionUpdate(t *testing.T) {
+	e1 := NewDistribution("test", 5)
+	e2 := &Distribution{Name: "test", Values: []float64{1.5, 2}} // Count is optional
+	err := e1.Update(e2)
+	if nil != err {
+		t.Error(err)
+	}
+	if 3 != e1.Count {
+		t.Errorf("wrong number of values seen: expected 3, actual %d", e1.Count)
+	}
+
+	expected := []string{"test:5|d", "test:1.5|d", "test:2|d"}
+	actual := e1.Stats()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, actual, actual)
+	}
+}
@@ -1,11 +1,15 @@
 package event
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+)
 
 // Increment represents a metric whose value is averaged over a minute
 type Increment struct {
 	Name  string
 	Value int64
+	Tags  []string
 }
 
 // Update the event with metrics coming from a new one of the same type and with the same key
@@ -24,7 +28,15 @@ func (e Increment) Payload() interface{} {
 
 // Stats returns an array of StatsD events as they travel over UDP
 func (e Increment) Stats() []string {
-	return []string{fmt.Sprintf("%s:%d|c", e.Name, e.Value)}
+	tags := tagSuffix(e.Tags)
+	return []string{fmt.Sprintf("%s:%d|c%s", e.Name, e.Value, tags)}
+}
+
+// AppendStats appends the StatsD lines of Stats() to buf, without intermediate allocations
+func (e Increment) AppendStats(buf []byte, prefix string) []byte {
+	buf = appendName(buf, prefix, e.Name, "")
+	buf = strconv.AppendInt(buf, e.Value, 10)
+	return appendType(buf, "c", e.Tags)
 }
 
 // Key returns the name of this metric
@@ -37,6 +49,16 @@ func (e *Increment) SetKey(key string) {
 	e.Name = key
 }
 
+// GetTags returns the tags attached to this metric
+func (e Increment) GetTags() []string {
+	return e.Tags
+}
+
+// SetTags sets the tags attached to this metric
+func (e *Increment) SetTags(tags []string) {
+	e.Tags = tags
+}
+
 // Type returns an integer identifier for this type of metric
 func (e Increment) Type() int {
 	return EventIncr
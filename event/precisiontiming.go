@@ -2,21 +2,27 @@ package event
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 )
 
-// PrecisionTiming keeps min/max/avg information about a timer over a certain interval
+// PrecisionTiming keeps min/max/avg information about a timer over a certain interval.
+// If Percentiles is set (e.g. []float64{50, 90, 99}), the values are also added
+// to a quantile sketch, and the percentiles are sent as ".p50", ".p90", ".p99".
 type PrecisionTiming struct {
-	Name  string
-	Min   time.Duration
-	Max   time.Duration
-	Value time.Duration
-	Count int64
+	Name        string
+	Min         time.Duration
+	Max         time.Duration
+	Value       time.Duration
+	Count       int64
+	Tags        []string
+	Percentiles []float64
+	Sketch      *Sketch
 }
 
 // NewPrecisionTiming is a factory for a Timing event, setting the Count to 1 to prevent div_by_0 errors
-func NewPrecisionTiming(k string, delta time.Duration) *PrecisionTiming {
-	return &PrecisionTiming{Name: k, Min: delta, Max: delta, Value: delta, Count: 1}
+func NewPrecisionTiming(k string, delta time.Duration, tags ...string) *PrecisionTiming {
+	return &PrecisionTiming{Name: k, Min: delta, Max: delta, Value: delta, Count: 1, Tags: tags}
 }
 
 // Update the event with metrics coming from a new one of the same type and with the same key
@@ -25,10 +31,18 @@ func (e *PrecisionTiming) Update(e2 Event) error {
 		return fmt.Errorf("statsd event type conflict: %s vs %s ", e.String(), e2.String())
 	}
 	p := e2.Payload().(PrecisionTiming)
+	if len(e.Percentiles) > 0 {
+		s := e.sketch() // before updating the other values
+		if nil != p.Sketch {
+			s.Merge(p.Sketch)
+		} else {
+			s.AddN(float64(p.Value)/float64(p.Count), p.Count)
+		}
+	}
 	e.Count += p.Count
 	e.Value += p.Value
 	e.Min = time.Duration(minInt64(int64(e.Min), int64(p.Min)))
-	e.Max = time.Duration(maxInt64(int64(e.Max), int64(p.Min)))
+	e.Max = time.Duration(maxInt64(int64(e.Max), int64(p.Max)))
 	return nil
 }
 
@@ -39,12 +53,58 @@ func (e PrecisionTiming) Payload() interface{} {
 
 // Stats returns an array of StatsD events as they travel over UDP
 func (e PrecisionTiming) Stats() []string {
-	return []string{
-		fmt.Sprintf("%s.count:%d|c", e.Name, e.Count),
-		fmt.Sprintf("%s.avg:%.6f|ms", e.Name, float64(int64(e.Value)/e.Count)/1000000), // make sure e.Count != 0
-		fmt.Sprintf("%s.min:%.6f|ms", e.Name, e.durationToMs(e.Min)),
-		fmt.Sprintf("%s.max:%.6f|ms", e.Name, e.durationToMs(e.Max)),
+	tags := tagSuffix(e.Tags)
+	ret := []string{
+		fmt.Sprintf("%s.count:%d|c%s", e.Name, e.Count, tags),
+		fmt.Sprintf("%s.avg:%.6f|ms%s", e.Name, float64(int64(e.Value)/e.Count)/1000000, tags), // make sure e.Count != 0
+		fmt.Sprintf("%s.min:%.6f|ms%s", e.Name, e.durationToMs(e.Min), tags),
+		fmt.Sprintf("%s.max:%.6f|ms%s", e.Name, e.durationToMs(e.Max), tags),
+	}
+	if len(e.Percentiles) > 0 {
+		s := e.sketch()
+		for _, pct := range e.Percentiles {
+			v := time.Duration(s.Quantile(pct / 100))
+			ret = append(ret, fmt.Sprintf("%s.%s:%.6f|ms%s", e.Name, percentileName(pct), e.durationToMs(v), tags))
+		}
+	}
+	return ret
+}
+
+// AppendStats appends the StatsD lines of Stats() to buf, without intermediate allocations
+func (e PrecisionTiming) AppendStats(buf []byte, prefix string) []byte {
+	buf = appendName(buf, prefix, e.Name, ".count")
+	buf = strconv.AppendInt(buf, e.Count, 10)
+	buf = appendType(buf, "c", e.Tags)
+	buf = appendName(buf, prefix, e.Name, ".avg")
+	buf = appendMs(buf, float64(int64(e.Value)/e.Count)/1000000) // make sure e.Count != 0
+	buf = appendType(buf, "ms", e.Tags)
+	buf = appendName(buf, prefix, e.Name, ".min")
+	buf = appendMs(buf, e.durationToMs(e.Min))
+	buf = appendType(buf, "ms", e.Tags)
+	buf = appendName(buf, prefix, e.Name, ".max")
+	buf = appendMs(buf, e.durationToMs(e.Max))
+	buf = appendType(buf, "ms", e.Tags)
+	if len(e.Percentiles) > 0 {
+		s := e.sketch()
+		for _, pct := range e.Percentiles {
+			buf = appendName(buf, prefix, e.Name, "."+percentileName(pct))
+			buf = appendMs(buf, e.durationToMs(time.Duration(s.Quantile(pct/100))))
+			buf = appendType(buf, "ms", e.Tags)
+		}
+	}
+	return buf
+}
+
+// sketch returns the quantile sketch for this timer (in nanoseconds), initialising
+// it with the values aggregated so far if it does not exist yet
+func (e *PrecisionTiming) sketch() *Sketch {
+	if nil == e.Sketch {
+		e.Sketch = NewSketch(SketchRelativeAccuracy)
+		if e.Count > 0 {
+			e.Sketch.AddN(float64(e.Value)/float64(e.Count), e.Count)
+		}
 	}
+	return e.Sketch
 }
 
 // durationToMs converts time.Duration into the corresponding value in milliseconds
@@ -62,6 +122,16 @@ func (e *PrecisionTiming) SetKey(key string) {
 	e.Name = key
 }
 
+// GetTags returns the tags attached to this metric
+func (e PrecisionTiming) GetTags() []string {
+	return e.Tags
+}
+
+// SetTags sets the tags attached to this metric
+func (e *PrecisionTiming) SetTags(tags []string) {
+	e.Tags = tags
+}
+
 // Type returns an integer identifier for this type of metric
 func (e PrecisionTiming) Type() int {
 	return EventPrecisionTiming
@@ -1,6 +1,8 @@
 package event
 
 import (
+	"fmt"
+	"math"
 	"reflect"
 	"testing"
 	"time"
@@ -25,3 +27,31 @@ func TestPrecisionTimingUpdate(t *testing.T) {
 		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, actual, actual)
 	}
 }
+
+func TestPrecisionTimingPercentiles(t *testing.T) {
+	e1 := NewPrecisionTiming("test", 2*time.Millisecond)
+	e1.Percentiles = []float64{50}
+	e2 := NewPrecisionTiming("test", 2*time.Millisecond)
+	e3 := NewPrecisionTiming("test", 9*time.Millisecond)
+	err := e1.Update(e2)
+	if nil != err {
+		t.Error(err)
+	}
+	err = e1.Update(e3)
+	if nil != err {
+		t.Error(err)
+	}
+
+	actual := e1.Stats()
+	if len(actual) != 5 || actual[3] != "test.max:9.000000|ms" {
+		t.Fatalf("unexpected metrics: %v", actual)
+	}
+	var p50 float64
+	if _, err := fmt.Sscanf(actual[4], "test.p50:%f|ms", &p50); nil != err {
+		t.Fatal(err)
+	}
+	// within the sketch relative accuracy
+	if math.Abs(p50-2) > 0.02 {
+		t.Errorf("unexpected percentile: %s", actual[4])
+	}
+}
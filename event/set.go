@@ -0,0 +1,103 @@
+package event
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Set counts the number of unique values seen for a metric over a certain interval.
+// Each distinct value is only kept (and flushed) once.
+type Set struct {
+	Name   string
+	Values map[string]struct{}
+	Tags   []string
+}
+
+// NewSet is a factory for a Set event with a single member
+func NewSet(k string, value string, tags ...string) *Set {
+	return &Set{Name: k, Values: map[string]struct{}{value: {}}, Tags: tags}
+}
+
+// Update the event with metrics coming from a new one of the same type and with the same key
+func (e *Set) Update(e2 Event) error {
+	if e.Type() != e2.Type() {
+		return fmt.Errorf("statsd event type conflict: %s vs %s ", e.String(), e2.String())
+	}
+	if nil == e.Values {
+		e.Values = make(map[string]struct{})
+	}
+	for v := range e2.Payload().(map[string]struct{}) {
+		e.Values[v] = struct{}{}
+	}
+	return nil
+}
+
+// Payload returns the aggregated value for this event
+func (e Set) Payload() interface{} {
+	return e.Values
+}
+
+// Stats returns an array of StatsD events as they travel over UDP
+func (e Set) Stats() []string {
+	tags := tagSuffix(e.Tags)
+	ret := make([]string, 0, len(e.Values))
+	for _, v := range e.members() {
+		ret = append(ret, fmt.Sprintf("%s:%s|s%s", e.Name, v, tags))
+	}
+	return ret
+}
+
+// AppendStats appends the StatsD lines of Stats() to buf, without intermediate allocations
+func (e Set) AppendStats(buf []byte, prefix string) []byte {
+	for _, v := range e.members() { // sorted, as in Stats
+		buf = appendName(buf, prefix, e.Name, "")
+		buf = append(buf, v...)
+		buf = appendType(buf, "s", e.Tags)
+	}
+	return buf
+}
+
+// members returns the unique values of the set, sorted
+func (e Set) members() []string {
+	ret := make([]string, 0, len(e.Values))
+	for v := range e.Values {
+		ret = append(ret, v)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Key returns the name of this metric
+func (e Set) Key() string {
+	return e.Name
+}
+
+// SetKey sets the name of this metric
+func (e *Set) SetKey(key string) {
+	e.Name = key
+}
+
+// GetTags returns the tags attached to this metric
+func (e Set) GetTags() []string {
+	return e.Tags
+}
+
+// SetTags sets the tags attached to this metric
+func (e *Set) SetTags(tags []string) {
+	e.Tags = tags
+}
+
+// Type returns an integer identifier for this type of metric
+func (e Set) Type() int {
+	return EventSet
+}
+
+// TypeString returns a name for this type of metric
+func (e Set) TypeString() string {
+	return "Set"
+}
+
+// String returns a debug-friendly representation of this metric
+func (e Set) String() string {
+	return fmt.Sprintf("{Type: %s, Key: %s, Values: %v}", e.TypeString(), e.Name, e.members())
+}
@@ -0,0 +1,26 @@
+package event
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetUpdate(t *testing.T) {
+	e1 := NewSet("test", "user1")
+	e2 := NewSet("test", "user2")
+	e3 := NewSet("test", "user1")
+	err := e1.Update(e2)
+	if nil != err {
+		t.Error(err)
+	}
+	err = e1.Update(e3)
+	if nil != err {
+		t.Error(err)
+	}
+
+	expected := []string{"test:user1|s", "test:user2|s"} // only unique values are flushed
+	actual := e1.Stats()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, actual, actual)
+	}
+}
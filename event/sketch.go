@@ -0,0 +1,166 @@
+package event
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SketchRelativeAccuracy is the relative accuracy of the quantiles computed
+// by the sketches created by the Timing and PrecisionTiming events
+// (e.g. 0.01 means the p99 returned is within 1% of the actual p99)
+var SketchRelativeAccuracy = 0.01
+
+// Sketch is a quantile sketch with relative-error guarantees (DDSketch).
+// Values are counted in logarithmically-sized bins, so the memory used only
+// depends on the range of the values, not on how many values are added.
+type Sketch struct {
+	gamma    float64
+	logGamma float64
+	bins     map[int]int64 // positive values
+	negBins  map[int]int64 // negative values, indexed by their absolute value
+	zeros    int64
+	count    int64
+	min      float64
+	max      float64
+}
+
+// NewSketch is a factory for a Sketch with the given relative accuracy (0 < accuracy < 1)
+func NewSketch(relativeAccuracy float64) *Sketch {
+	if relativeAccuracy <= 0 || relativeAccuracy >= 1 {
+		relativeAccuracy = SketchRelativeAccuracy
+	}
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return &Sketch{
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+		bins:     make(map[int]int64),
+		negBins:  make(map[int]int64),
+		min:      math.Inf(1),
+		max:      math.Inf(-1),
+	}
+}
+
+// Add a value to the sketch
+func (s *Sketch) Add(v float64) {
+	s.AddN(v, 1)
+}
+
+// AddN adds the same value n times to the sketch
+func (s *Sketch) AddN(v float64, n int64) {
+	if n <= 0 || math.IsNaN(v) {
+		return
+	}
+	switch {
+	case v > 0:
+		s.bins[s.index(v)] += n
+	case v < 0:
+		s.negBins[s.index(-v)] += n
+	default:
+		s.zeros += n
+	}
+	s.count += n
+	s.min = math.Min(s.min, v)
+	s.max = math.Max(s.max, v)
+}
+
+// Merge the values of another sketch into this one
+func (s *Sketch) Merge(o *Sketch) {
+	if nil == o || 0 == o.count {
+		return
+	}
+	if s.gamma != o.gamma {
+		// different accuracy: re-add the values at the centre of each bin
+		for i, n := range o.bins {
+			s.AddN(o.value(i), n)
+		}
+		for i, n := range o.negBins {
+			s.AddN(-o.value(i), n)
+		}
+		s.AddN(0, o.zeros)
+		s.min = math.Min(s.min, o.min)
+		s.max = math.Max(s.max, o.max)
+		return
+	}
+	for i, n := range o.bins {
+		s.bins[i] += n
+	}
+	for i, n := range o.negBins {
+		s.negBins[i] += n
+	}
+	s.zeros += o.zeros
+	s.count += o.count
+	s.min = math.Min(s.min, o.min)
+	s.max = math.Max(s.max, o.max)
+}
+
+// Count returns the number of values added to the sketch
+func (s *Sketch) Count() int64 {
+	return s.count
+}
+
+// Quantile returns the approximate value at the given quantile (0 <= q <= 1),
+// or 0 if the sketch is empty
+func (s *Sketch) Quantile(q float64) float64 {
+	if 0 == s.count {
+		return 0
+	}
+	if q <= 0 {
+		return s.min
+	}
+	if q >= 1 {
+		return s.max
+	}
+	rank := int64(q * float64(s.count-1))
+
+	// negative values first, from the largest absolute value
+	neg := sortedIndexes(s.negBins)
+	for i := len(neg) - 1; i >= 0; i-- {
+		if rank < s.negBins[neg[i]] {
+			return s.clamp(-s.value(neg[i]))
+		}
+		rank -= s.negBins[neg[i]]
+	}
+	if rank < s.zeros {
+		return 0
+	}
+	rank -= s.zeros
+	for _, i := range sortedIndexes(s.bins) {
+		if rank < s.bins[i] {
+			return s.clamp(s.value(i))
+		}
+		rank -= s.bins[i]
+	}
+	return s.max
+}
+
+// index returns the bin for a positive value
+func (s *Sketch) index(v float64) int {
+	return int(math.Ceil(math.Log(v) / s.logGamma))
+}
+
+// value returns the (positive) value at the centre of a bin
+func (s *Sketch) value(i int) float64 {
+	return 2 * math.Pow(s.gamma, float64(i)) / (s.gamma + 1)
+}
+
+// clamp makes sure the approximated value is within the range of the values added
+func (s *Sketch) clamp(v float64) float64 {
+	return math.Max(s.min, math.Min(s.max, v))
+}
+
+func sortedIndexes(bins map[int]int64) []int {
+	ret := make([]int, 0, len(bins))
+	for i := range bins {
+		ret = append(ret, i)
+	}
+	sort.Ints(ret)
+	return ret
+}
+
+// percentileName returns the suffix used for a percentile stat,
+// e.g. "p99" for 99 and "p99_9" for 99.9
+func percentileName(p float64) string {
+	return "p" + strings.Replace(strconv.FormatFloat(p, 'f', -1, 64), ".", "_", 1)
+}
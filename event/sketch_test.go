@@ -0,0 +1,54 @@
+package event
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSketchQuantile(t *testing.T) {
+	s := NewSketch(0.01)
+	for i := 1; i <= 1000; i++ {
+		s.Add(float64(i))
+	}
+	if 1000 != s.Count() {
+		t.Errorf("wrong count: expected 1000, actual %d", s.Count())
+	}
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		expected := q * 999
+		actual := s.Quantile(q)
+		if math.Abs(actual-expected) > expected*0.01+1 {
+			t.Errorf("quantile %g out of the expected accuracy: expected %g, actual %g", q, expected, actual)
+		}
+	}
+	if 1 != s.Quantile(0) || 1000 != s.Quantile(1) {
+		t.Errorf("wrong min/max: %g / %g", s.Quantile(0), s.Quantile(1))
+	}
+}
+
+func TestSketchMerge(t *testing.T) {
+	s1 := NewSketch(0.01)
+	s2 := NewSketch(0.02)
+	s1.Add(-5)
+	s1.Add(0)
+	for i := 0; i < 8; i++ {
+		s2.Add(100)
+	}
+	s1.Merge(s2)
+	if 10 != s1.Count() {
+		t.Errorf("wrong count: expected 10, actual %d", s1.Count())
+	}
+	if v := s1.Quantile(0.05); -5 != v {
+		t.Errorf("expected the negative value first, got %g", v)
+	}
+	if v := s1.Quantile(0.5); math.Abs(v-100) > 2 {
+		t.Errorf("wrong median: expected ~100, got %g", v)
+	}
+}
+
+func TestPercentileName(t *testing.T) {
+	for p, expected := range map[float64]string{50: "p50", 99: "p99", 99.9: "p99_9"} {
+		if actual := percentileName(p); expected != actual {
+			t.Errorf("wrong percentile name: expected %s, actual %s", expected, actual)
+		}
+	}
+}
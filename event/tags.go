@@ -0,0 +1,46 @@
+package event
+
+import (
+	"sort"
+	"strings"
+)
+
+// CanonicalTags returns a sorted copy of the given tags, without duplicates,
+// so that the same tag set always has the same representation
+func CanonicalTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	ret := make([]string, len(tags))
+	copy(ret, tags)
+	sort.Strings(ret)
+	n := 1
+	for i := 1; i < len(ret); i++ {
+		if ret[i] != ret[n-1] {
+			ret[n] = ret[i]
+			n++
+		}
+	}
+	return ret[:n]
+}
+
+// TagKey returns the canonical string representation of a tag set,
+// used to aggregate events with the same name and tags
+func TagKey(tags []string) string {
+	switch len(tags) {
+	case 0:
+		return ""
+	case 1:
+		return tags[0]
+	}
+	return strings.Join(CanonicalTags(tags), ",")
+}
+
+// tagSuffix returns the DogStatsD-style tag section ("|#k1:v1,k2:v2")
+// to append to a StatsD line, or an empty string if there are no tags
+func tagSuffix(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "|#" + strings.Join(tags, ",")
+}
@@ -0,0 +1,44 @@
+package event
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalTags(t *testing.T) {
+	expected := []string{"env:prod", "route:/api"}
+	actual := CanonicalTags([]string{"route:/api", "env:prod", "route:/api"})
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("unexpected canonical tags: Expected: %v, Actual: %v ", expected, actual)
+	}
+	if nil != CanonicalTags(nil) {
+		t.Errorf("expected nil canonical tags for an empty tag set")
+	}
+}
+
+func TestTagKey(t *testing.T) {
+	k1 := TagKey([]string{"route:/api", "env:prod"})
+	k2 := TagKey([]string{"env:prod", "route:/api", "env:prod"})
+	if k1 != k2 {
+		t.Errorf("the same tag set should have the same key: %s vs %s", k1, k2)
+	}
+	if "env:prod,route:/api" != k1 {
+		t.Errorf("unexpected tag key: %s", k1)
+	}
+}
+
+func TestStatsWithTags(t *testing.T) {
+	e1 := &Increment{Name: "test", Value: 5, Tags: []string{"env:prod", "route:/api"}}
+	expected := []string{"test:5|c|#env:prod,route:/api"}
+	actual := e1.Stats()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, actual, actual)
+	}
+
+	e2 := NewTiming("test", 5, "env:prod")
+	expected = []string{"test.count:1|c|#env:prod", "test.avg:5|ms|#env:prod", "test.min:5|ms|#env:prod", "test.max:5|ms|#env:prod"}
+	actual = e2.Stats()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, actual, actual)
+	}
+}
@@ -1,19 +1,28 @@
 package event
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
 
-// Timing keeps min/max/avg information about a timer over a certain interval
+// Timing keeps min/max/avg information about a timer over a certain interval.
+// If Percentiles is set (e.g. []float64{50, 90, 99}), the values are also added
+// to a quantile sketch, and the percentiles are sent as ".p50", ".p90", ".p99".
 type Timing struct {
-	Name  string
-	Min   int64
-	Max   int64
-	Value int64
-	Count int64
+	Name        string
+	Min         int64
+	Max         int64
+	Value       int64
+	Count       int64
+	Tags        []string
+	Percentiles []float64
+	Sketch      *Sketch
 }
 
 // NewTiming is a factory for a Timing event, setting the Count to 1 to prevent div_by_0 errors
-func NewTiming(k string, delta int64) *Timing {
-	return &Timing{Name: k, Min: delta, Max: delta, Value: delta, Count: 1}
+func NewTiming(k string, delta int64, tags ...string) *Timing {
+	return &Timing{Name: k, Min: delta, Max: delta, Value: delta, Count: 1, Tags: tags}
 }
 
 // Update the event with metrics coming from a new one of the same type and with the same key
@@ -22,6 +31,14 @@ func (e *Timing) Update(e2 Event) error {
 		return fmt.Errorf("statsd event type conflict: %s vs %s ", e.String(), e2.String())
 	}
 	p := e2.Payload().(map[string]int64)
+	if len(e.Percentiles) > 0 {
+		s := e.sketch() // before updating the other values
+		if t, ok := e2.(*Timing); ok && nil != t.Sketch {
+			s.Merge(t.Sketch)
+		} else {
+			s.AddN(float64(p["val"])/float64(p["cnt"]), p["cnt"])
+		}
+	}
 	e.Count += p["cnt"]
 	e.Value += p["val"]
 	e.Min = minInt64(e.Min, p["min"])
@@ -41,12 +58,58 @@ func (e Timing) Payload() interface{} {
 
 // Stats returns an array of StatsD events as they travel over UDP
 func (e Timing) Stats() []string {
-	return []string{
-		fmt.Sprintf("%s.count:%d|c", e.Name, e.Count),
-		fmt.Sprintf("%s.avg:%d|ms", e.Name, int64(e.Value/e.Count)), // make sure e.Count != 0
-		fmt.Sprintf("%s.min:%d|ms", e.Name, e.Min),
-		fmt.Sprintf("%s.max:%d|ms", e.Name, e.Max),
+	tags := tagSuffix(e.Tags)
+	ret := []string{
+		fmt.Sprintf("%s.count:%d|c%s", e.Name, e.Count, tags),
+		fmt.Sprintf("%s.avg:%d|ms%s", e.Name, int64(e.Value/e.Count), tags), // make sure e.Count != 0
+		fmt.Sprintf("%s.min:%d|ms%s", e.Name, e.Min, tags),
+		fmt.Sprintf("%s.max:%d|ms%s", e.Name, e.Max, tags),
+	}
+	if len(e.Percentiles) > 0 {
+		s := e.sketch()
+		for _, pct := range e.Percentiles {
+			v := int64(math.Floor(s.Quantile(pct/100) + 0.5))
+			ret = append(ret, fmt.Sprintf("%s.%s:%d|ms%s", e.Name, percentileName(pct), v, tags))
+		}
+	}
+	return ret
+}
+
+// AppendStats appends the StatsD lines of Stats() to buf, without intermediate allocations
+func (e Timing) AppendStats(buf []byte, prefix string) []byte {
+	buf = appendName(buf, prefix, e.Name, ".count")
+	buf = strconv.AppendInt(buf, e.Count, 10)
+	buf = appendType(buf, "c", e.Tags)
+	buf = appendName(buf, prefix, e.Name, ".avg")
+	buf = strconv.AppendInt(buf, int64(e.Value/e.Count), 10) // make sure e.Count != 0
+	buf = appendType(buf, "ms", e.Tags)
+	buf = appendName(buf, prefix, e.Name, ".min")
+	buf = strconv.AppendInt(buf, e.Min, 10)
+	buf = appendType(buf, "ms", e.Tags)
+	buf = appendName(buf, prefix, e.Name, ".max")
+	buf = strconv.AppendInt(buf, e.Max, 10)
+	buf = appendType(buf, "ms", e.Tags)
+	if len(e.Percentiles) > 0 {
+		s := e.sketch()
+		for _, pct := range e.Percentiles {
+			buf = appendName(buf, prefix, e.Name, "."+percentileName(pct))
+			buf = strconv.AppendInt(buf, int64(math.Floor(s.Quantile(pct/100)+0.5)), 10)
+			buf = appendType(buf, "ms", e.Tags)
+		}
+	}
+	return buf
+}
+
+// sketch returns the quantile sketch for this timer, initialising it
+// with the values aggregated so far if it does not exist yet
+func (e *Timing) sketch() *Sketch {
+	if nil == e.Sketch {
+		e.Sketch = NewSketch(SketchRelativeAccuracy)
+		if e.Count > 0 {
+			e.Sketch.AddN(float64(e.Value)/float64(e.Count), e.Count)
+		}
 	}
+	return e.Sketch
 }
 
 // Key returns the name of this metric
@@ -59,6 +122,16 @@ func (e *Timing) SetKey(key string) {
 	e.Name = key
 }
 
+// GetTags returns the tags attached to this metric
+func (e Timing) GetTags() []string {
+	return e.Tags
+}
+
+// SetTags sets the tags attached to this metric
+func (e *Timing) SetTags(tags []string) {
+	e.Tags = tags
+}
+
 // Type returns an integer identifier for this type of metric
 func (e Timing) Type() int {
 	return EventTiming
@@ -24,3 +24,20 @@ func TestTimingUpdate(t *testing.T) {
 		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, actual, actual)
 	}
 }
+
+func TestTimingPercentiles(t *testing.T) {
+	e1 := NewTiming("test", 1)
+	e1.Percentiles = []float64{50, 99}
+	for i := int64(2); i <= 100; i++ {
+		err := e1.Update(NewTiming("test", i))
+		if nil != err {
+			t.Error(err)
+		}
+	}
+
+	expected := []string{"test.count:100|c", "test.avg:50|ms", "test.min:1|ms", "test.max:100|ms", "test.p50:50|ms", "test.p99:99|ms"}
+	actual := e1.Stats()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, actual, actual)
+	}
+}
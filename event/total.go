@@ -1,11 +1,15 @@
 package event
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+)
 
 // Total represents a metric that is continously increasing, e.g. read operations since boot
 type Total struct {
 	Name  string
 	Value int64
+	Tags  []string
 }
 
 // Update the event with metrics coming from a new one of the same type and with the same key
@@ -24,7 +28,15 @@ func (e Total) Payload() interface{} {
 
 // Stats returns an array of StatsD events as they travel over UDP
 func (e Total) Stats() []string {
-	return []string{fmt.Sprintf("%s:%d|t", e.Name, e.Value)}
+	tags := tagSuffix(e.Tags)
+	return []string{fmt.Sprintf("%s:%d|t%s", e.Name, e.Value, tags)}
+}
+
+// AppendStats appends the StatsD lines of Stats() to buf, without intermediate allocations
+func (e Total) AppendStats(buf []byte, prefix string) []byte {
+	buf = appendName(buf, prefix, e.Name, "")
+	buf = strconv.AppendInt(buf, e.Value, 10)
+	return appendType(buf, "t", e.Tags)
 }
 
 // Key returns the name of this metric
@@ -37,6 +49,16 @@ func (e *Total) SetKey(key string) {
 	e.Name = key
 }
 
+// GetTags returns the tags attached to this metric
+func (e Total) GetTags() []string {
+	return e.Tags
+}
+
+// SetTags sets the tags attached to this metric
+func (e *Total) SetTags(tags []string) {
+	e.Tags = tags
+}
+
 // Type returns an integer identifier for this type of metric
 func (e Total) Type() int {
 	return EventTotal
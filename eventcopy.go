@@ -0,0 +1,131 @@
+package statsd
+
+import (
+	"strings"
+
+	"github.com/quipo/statsd/event"
+)
+
+// copyEvent returns a deep copy of a built-in event, or nil for the other events
+func copyEvent(e event.Event) event.Event {
+	switch v := e.(type) {
+	case *event.Increment:
+		c := *v
+		return &c
+	case *event.Gauge:
+		c := *v
+		return &c
+	case *event.GaugeDelta:
+		c := *v
+		return &c
+	case *event.FGauge:
+		c := *v
+		return &c
+	case *event.FGaugeDelta:
+		c := *v
+		return &c
+	case *event.Total:
+		c := *v
+		return &c
+	case *event.Absolute:
+		c := *v
+		c.Values = append([]int64(nil), v.Values...)
+		return &c
+	case *event.FAbsolute:
+		c := *v
+		c.Values = append([]float64(nil), v.Values...)
+		return &c
+	case *event.Histogram:
+		c := *v
+		c.Values = append([]float64(nil), v.Values...)
+		return &c
+	case *event.Distribution:
+		c := *v
+		c.Values = append([]float64(nil), v.Values...)
+		return &c
+	case *event.Set:
+		c := *v
+		c.Values = make(map[string]struct{}, len(v.Values))
+		for value := range v.Values {
+			c.Values[value] = struct{}{}
+		}
+		return &c
+	case *event.Timing:
+		c := *v
+		c.Sketch = copySketch(v.Sketch)
+		return &c
+	case *event.PrecisionTiming:
+		c := *v
+		c.Sketch = copySketch(v.Sketch)
+		return &c
+	}
+	return nil
+}
+
+func copySketch(s *event.Sketch) *event.Sketch {
+	if nil == s {
+		return nil
+	}
+	c := event.NewSketch(event.SketchRelativeAccuracy)
+	c.Merge(s)
+	return c
+}
+
+// renamedCopy returns an event like e, with another name and other tags, leaving e unchanged:
+// a copy of a built-in event, or a renamedEvent wrapping the other events
+func renamedCopy(e event.Event, name string, tags []string) event.Event {
+	if c := copyEvent(e); nil != c {
+		c.SetKey(name)
+		c.SetTags(tags)
+		return c
+	}
+	return &renamedEvent{Event: e, name: name, tags: tags}
+}
+
+// renamedEvent is an event which can't be copied, sent with another name and other tags.
+// It's aggregated like the event it wraps.
+type renamedEvent struct {
+	event.Event
+	name string
+	tags []string
+}
+
+// Key returns the new name of the event
+func (e *renamedEvent) Key() string {
+	return e.name
+}
+
+// SetKey renames the event
+func (e *renamedEvent) SetKey(key string) {
+	e.name = key
+}
+
+// GetTags returns the new tags of the event
+func (e *renamedEvent) GetTags() []string {
+	return e.tags
+}
+
+// SetTags sets the tags of the event
+func (e *renamedEvent) SetTags(tags []string) {
+	e.tags = tags
+}
+
+// Stats returns the lines of the wrapped event, with the new name and tags
+func (e *renamedEvent) Stats() []string {
+	key := e.Event.Key()
+	stats := e.Event.Stats()
+	ret := make([]string, 0, len(stats))
+	for _, line := range stats {
+		if strings.HasPrefix(line, key) {
+			line = e.name + line[len(key):]
+		}
+		if i := strings.Index(line, "|#"); i >= 0 {
+			line = line[:i] // the tags are the last section
+		}
+		if len(e.tags) > 0 {
+			line += "|#" + strings.Join(e.tags, ",")
+		}
+		ret = append(ret, line)
+	}
+	return ret
+}
@@ -0,0 +1,315 @@
+package statsd
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/quipo/statsd/event"
+)
+
+// DefaultHealthInterval is how often a FailoverClient checks the health of its clients, by default
+var DefaultHealthInterval = 5 * time.Second
+
+// FailoverClient sends the metrics to a primary StatsdClient, switching to the next standby
+// when a write fails (or the optional HealthCheck fails), and switching back to the first
+// healthy client, preferring the primary, once it recovers.
+// A metric whose write failed is sent again to the next client, so with SendEvents
+// some events might be sent twice if only some of their packets were written.
+type FailoverClient struct {
+	// HealthCheck, if set, is called for every client at every HealthInterval, and the clients
+	// returning an error are not used. Otherwise the clients are only considered unhealthy after
+	// a write error. Unhealthy clients are healthy again once they can connect again.
+	HealthCheck func(client *StatsdClient) error
+	// HealthInterval is how often the health of the clients is checked (DefaultHealthInterval if 0).
+	// Must be set before creating the sockets.
+	HealthInterval time.Duration
+	// OnFailover, if set, is called when the client in use changes, with their addresses
+	OnFailover func(from string, to string)
+
+	clients []*StatsdClient
+
+	mu       sync.RWMutex
+	active   int
+	healthy  []bool
+	sockType socketType
+	done     chan struct{}
+}
+
+// NewFailoverClient - Factory
+func NewFailoverClient(primary *StatsdClient, standby ...*StatsdClient) *FailoverClient {
+	clients := append([]*StatsdClient{primary}, standby...)
+	healthy := make([]bool, len(clients))
+	for i := range healthy {
+		healthy[i] = true
+	}
+	return &FailoverClient{
+		clients: clients,
+		healthy: healthy,
+	}
+}
+
+// Active returns the client the metrics are currently sent to
+func (f *FailoverClient) Active() *StatsdClient {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.clients[f.active]
+}
+
+// CreateSocket creates a UDP connection for every client
+func (f *FailoverClient) CreateSocket() error {
+	return f.dial(udpSocket)
+}
+
+// CreateTCPSocket creates a TCP connection for every client. It only fails if no client
+// can connect: the clients which can't are marked as unhealthy until they recover.
+func (f *FailoverClient) CreateTCPSocket() error {
+	return f.dial(tcpSocket)
+}
+
+func (f *FailoverClient) dial(sockType socketType) error {
+	var errs []error
+	f.mu.Lock()
+	f.sockType = sockType
+	for i, client := range f.clients {
+		err := client.dial(sockType)
+		f.healthy[i] = nil == err
+		if nil != err {
+			errs = append(errs, err)
+		}
+	}
+	f.active = f.firstHealthy()
+	if nil != f.done {
+		close(f.done)
+	}
+	f.done = make(chan struct{})
+	interval := f.HealthInterval
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+	go f.healthLoop(interval, f.done)
+	f.mu.Unlock()
+
+	if len(errs) == len(f.clients) {
+		return errs[0]
+	}
+	return nil
+}
+
+// firstHealthy returns the index of the first healthy client, or the active one
+// if none is healthy. Must be called with the lock held.
+func (f *FailoverClient) firstHealthy() int {
+	for i, ok := range f.healthy {
+		if ok {
+			return i
+		}
+	}
+	return f.active
+}
+
+// Close all the clients
+func (f *FailoverClient) Close() error {
+	f.mu.Lock()
+	if nil != f.done {
+		close(f.done)
+		f.done = nil
+	}
+	f.mu.Unlock()
+	var ret error
+	for _, client := range f.clients {
+		if err := client.Close(); nil != err && nil == ret {
+			ret = err
+		}
+	}
+	return ret
+}
+
+func (f *FailoverClient) healthLoop(interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			f.checkHealth()
+		}
+	}
+}
+
+// checkHealth updates the health of the clients, switching back to the first healthy one
+func (f *FailoverClient) checkHealth() {
+	f.mu.RLock()
+	healthy := make([]bool, len(f.healthy))
+	copy(healthy, f.healthy)
+	sockType := f.sockType
+	f.mu.RUnlock()
+
+	// only the health found by the check is updated: a write failing meanwhile is not undone
+	changed := make(map[int]bool)
+	for i, client := range f.clients {
+		if nil != f.HealthCheck && nil != f.HealthCheck(client) {
+			changed[i] = false
+			continue
+		}
+		if !healthy[i] {
+			// the connection might be broken: connect again
+			changed[i] = nil == client.dial(sockType)
+		}
+	}
+
+	f.mu.Lock()
+	for i, ok := range changed {
+		f.healthy[i] = ok
+	}
+	f.switchTo(f.firstHealthy())
+}
+
+// switchTo makes a client the active one, and notifies the change.
+// Must be called with the lock held, which is released.
+func (f *FailoverClient) switchTo(i int) {
+	from := f.active
+	f.active = i
+	f.mu.Unlock()
+	if from != i && nil != f.OnFailover {
+		f.OnFailover(f.clients[from].String(), f.clients[i].String())
+	}
+}
+
+// failed marks a client as unhealthy after a write error, switching to the next healthy one
+func (f *FailoverClient) failed(i int) {
+	f.mu.Lock()
+	f.healthy[i] = false
+	if f.active != i {
+		f.mu.Unlock()
+		return
+	}
+	next := f.active
+	for j := range f.clients {
+		if k := (i + 1 + j) % len(f.clients); f.healthy[k] {
+			next = k
+			break
+		}
+	}
+	f.switchTo(next)
+}
+
+// do calls fn with the active client and, if it fails to write, with the next healthy ones
+func (f *FailoverClient) do(fn func(*StatsdClient) error) error {
+	f.mu.RLock()
+	start := f.active
+	f.mu.RUnlock()
+
+	var err error
+	for j := range f.clients {
+		i := (start + j) % len(f.clients)
+		if j > 0 {
+			f.mu.RLock()
+			ok := f.healthy[i]
+			f.mu.RUnlock()
+			if !ok {
+				continue
+			}
+		}
+		if err = fn(f.clients[i]); nil == err {
+			return nil
+		}
+		if !isConnError(err) {
+			// e.g. ErrInvalidCount: the other clients would reject the metric too
+			return err
+		}
+		f.failed(i)
+	}
+	return err
+}
+
+// isConnError tells whether an error comes from the connection to the server,
+// rather than from the arguments of the call
+func isConnError(err error) bool {
+	if errNotConnected == err {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Incr - Increment a counter metric. Often used to note a particular event
+func (f *FailoverClient) Incr(stat string, count int64, tags ...string) error {
+	return f.do(func(c *StatsdClient) error { return c.Incr(stat, count, tags...) })
+}
+
+// Decr - Decrement a counter metric. Often used to note a particular event
+func (f *FailoverClient) Decr(stat string, count int64, tags ...string) error {
+	return f.do(func(c *StatsdClient) error { return c.Decr(stat, count, tags...) })
+}
+
+// Timing - Track a duration event
+// the time delta must be given in milliseconds
+func (f *FailoverClient) Timing(stat string, delta int64, tags ...string) error {
+	return f.do(func(c *StatsdClient) error { return c.Timing(stat, delta, tags...) })
+}
+
+// PrecisionTiming - Track a duration event
+// the time delta has to be a duration
+func (f *FailoverClient) PrecisionTiming(stat string, delta time.Duration, tags ...string) error {
+	return f.do(func(c *StatsdClient) error { return c.PrecisionTiming(stat, delta, tags...) })
+}
+
+// Gauge - Gauges are a constant data type. They are not subject to averaging,
+// and they don’t change unless you change them. That is, once you set a gauge value,
+// it will be a flat line on the graph until you change it again
+func (f *FailoverClient) Gauge(stat string, value int64, tags ...string) error {
+	return f.do(func(c *StatsdClient) error { return c.Gauge(stat, value, tags...) })
+}
+
+// GaugeDelta records a delta from the previous value (as int64)
+func (f *FailoverClient) GaugeDelta(stat string, value int64, tags ...string) error {
+	return f.do(func(c *StatsdClient) error { return c.GaugeDelta(stat, value, tags...) })
+}
+
+// FGauge is a Gauge working with float64 values
+func (f *FailoverClient) FGauge(stat string, value float64, tags ...string) error {
+	return f.do(func(c *StatsdClient) error { return c.FGauge(stat, value, tags...) })
+}
+
+// FGaugeDelta records a delta from the previous value (as float64)
+func (f *FailoverClient) FGaugeDelta(stat string, value float64, tags ...string) error {
+	return f.do(func(c *StatsdClient) error { return c.FGaugeDelta(stat, value, tags...) })
+}
+
+// Absolute - Send absolute-valued metric (not averaged/aggregated)
+func (f *FailoverClient) Absolute(stat string, value int64, tags ...string) error {
+	return f.do(func(c *StatsdClient) error { return c.Absolute(stat, value, tags...) })
+}
+
+// FAbsolute - Send absolute-valued floating point metric (not averaged/aggregated)
+func (f *FailoverClient) FAbsolute(stat string, value float64, tags ...string) error {
+	return f.do(func(c *StatsdClient) error { return c.FAbsolute(stat, value, tags...) })
+}
+
+// Total - Send a metric that is continously increasing, e.g. read operations since boot
+func (f *FailoverClient) Total(stat string, value int64, tags ...string) error {
+	return f.do(func(c *StatsdClient) error { return c.Total(stat, value, tags...) })
+}
+
+// Set - Send a value to be counted as unique per flush interval (e.g. unique users)
+func (f *FailoverClient) Set(stat string, value string, tags ...string) error {
+	return f.do(func(c *StatsdClient) error { return c.Set(stat, value, tags...) })
+}
+
+// Histogram - Send a value to be aggregated into a statistical distribution by the server (per host)
+func (f *FailoverClient) Histogram(stat string, value float64, tags ...string) error {
+	return f.do(func(c *StatsdClient) error { return c.Histogram(stat, value, tags...) })
+}
+
+// Distribution - Send a value to be aggregated into a statistical distribution
+// by the server (globally, across all hosts)
+func (f *FailoverClient) Distribution(stat string, value float64, tags ...string) error {
+	return f.do(func(c *StatsdClient) error { return c.Distribution(stat, value, tags...) })
+}
+
+// SendEvents - Sends stats from all the event objects
+func (f *FailoverClient) SendEvents(events map[string]event.Event) error {
+	return f.do(func(c *StatsdClient) error { return c.SendEvents(events) })
+}
@@ -0,0 +1,224 @@
+package statsd
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/quipo/statsd/statsdtest"
+)
+
+var _ Statsd = (*FailoverClient)(nil)
+
+func TestFailoverClientHealthCheck(t *testing.T) {
+	primary, err := statsdtest.NewServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+	standby, err := statsdtest.NewServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer standby.Close()
+
+	var down int32
+	switched := make(chan string, 10)
+	client := NewFailoverClient(NewStatsdClient(primary.Addr, "test."), NewStatsdClient(standby.Addr, "test."))
+	client.HealthInterval = 10 * time.Millisecond
+	client.HealthCheck = func(c *StatsdClient) error {
+		if c.String() == primary.Addr && 1 == atomic.LoadInt32(&down) {
+			return errors.New("unhealthy")
+		}
+		return nil
+	}
+	client.OnFailover = func(from string, to string) {
+		switched <- to
+	}
+	if err = client.CreateSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	waitSwitch := func(to string) {
+		select {
+		case addr := <-switched:
+			if to != addr {
+				t.Fatalf("switched to the wrong client: Expected: %s, Actual: %s", to, addr)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("did not switch to %s", to)
+		}
+	}
+
+	client.Incr("req", 1)
+	if err = primary.WaitFor(1); nil != err {
+		t.Fatal(err)
+	}
+
+	atomic.StoreInt32(&down, 1)
+	waitSwitch(standby.Addr)
+	client.Incr("req", 2)
+	if err = standby.WaitFor(1); nil != err {
+		t.Fatal(err)
+	}
+
+	// switch back once the primary recovers
+	atomic.StoreInt32(&down, 0)
+	waitSwitch(primary.Addr)
+	client.Incr("req", 3)
+	if err = primary.WaitFor(2); nil != err {
+		t.Fatal(err)
+	}
+	primary.AssertCounter(t, "test.req", 4)
+	standby.AssertCounter(t, "test.req", 2)
+}
+
+func TestFailoverClientWriteError(t *testing.T) {
+	primary, err := statsdtest.NewTCPServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+	standby, err := statsdtest.NewTCPServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer standby.Close()
+	// nothing listens to this address
+	unreachable, err := statsdtest.NewTCPServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	unreachable.Close()
+
+	// a client which can't connect is skipped
+	client := NewFailoverClient(NewStatsdClient(unreachable.Addr, "test."), NewStatsdClient(primary.Addr, "test."))
+	if err = client.CreateTCPSocket(); nil != err {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if primary.Addr != client.Active().String() {
+		t.Errorf("wrong active client: Expected: %s, Actual: %s", primary.Addr, client.Active())
+	}
+	client.Close()
+
+	all := NewFailoverClient(NewStatsdClient(unreachable.Addr, "test."))
+	if err = all.CreateTCPSocket(); nil == err {
+		t.Error("expected an error when no client can connect")
+	}
+	all.Close()
+
+	client = NewFailoverClient(NewStatsdClient(primary.Addr, "test."), NewStatsdClient(standby.Addr, "test."))
+	client.HealthInterval = time.Hour
+	if err = client.CreateTCPSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err = client.Incr("req", 1); nil != err {
+		t.Fatal(err)
+	}
+	if err = primary.WaitFor(1); nil != err {
+		t.Fatal(err)
+	}
+
+	// the primary goes away: the writes fail after a while, and are sent to the standby
+	primary.Close()
+	for i := 0; i < 100 && client.Active().String() == primary.Addr; i++ {
+		if err = client.Incr("req", 1); nil != err {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if standby.Addr != client.Active().String() {
+		t.Fatal("did not switch to the standby client")
+	}
+	if err = client.Gauge("g", 5); nil != err {
+		t.Fatal(err)
+	}
+	if err = standby.WaitFor(2); nil != err {
+		t.Fatal(err)
+	}
+	standby.AssertGauge(t, "test.g", 5)
+}
+
+func TestFailoverClientInvalidArgument(t *testing.T) {
+	srv, err := statsdtest.NewServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	switched := 0
+	client := NewFailoverClient(NewStatsdClient(srv.Addr, "test."), NewStatsdClient(srv.Addr, "test."))
+	client.HealthInterval = time.Hour
+	client.OnFailover = func(from string, to string) {
+		switched++
+	}
+	if err = client.CreateSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// the arguments are invalid for every client: no failover
+	if err = client.Incr("req", 0); ErrInvalidCount != err {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if 0 != switched {
+		t.Errorf("switched client after an invalid argument")
+	}
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	for i, ok := range client.healthy {
+		if !ok {
+			t.Errorf("client %d marked as unhealthy after an invalid argument", i)
+		}
+	}
+}
+
+type closingConn struct {
+	MockNetConn
+	closed bool
+}
+
+func (c *closingConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRedialClosesConnection(t *testing.T) {
+	srv, err := statsdtest.NewServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	client := NewStatsdClient(srv.Addr, "test.")
+	old := &closingConn{}
+	client.conn = old
+	if err = client.CreateSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if !old.closed {
+		t.Error("the previous connection was not closed")
+	}
+}
+
+func TestFailoverClientFailureDuringHealthCheck(t *testing.T) {
+	primary := NewStatsdClient("127.0.0.1:1201", "test.")
+	standby := NewStatsdClient("127.0.0.1:1202", "test.")
+	client := NewFailoverClient(primary, standby)
+	client.HealthCheck = func(c *StatsdClient) error {
+		if primary == c {
+			// a write fails while the health is being checked
+			client.failed(0)
+		}
+		return nil
+	}
+	client.checkHealth()
+	if standby != client.Active() {
+		t.Errorf("switched back to the failed client: Expected: %s, Actual: %s", standby, client.Active())
+	}
+}
@@ -0,0 +1,453 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quipo/statsd/event"
+)
+
+// RuleAction is what a Rule does with the metrics matching it
+type RuleAction int
+
+// rule actions
+const (
+	// RuleAllow sends the metric, without evaluating the next rules (e.g. for an allowlist,
+	// followed by a rule dropping everything else)
+	RuleAllow RuleAction = iota
+	// RuleDrop drops the metric
+	RuleDrop
+	// RuleRename renames the metric, and evaluates the next rules with the new name
+	RuleRename
+	// RuleSample only sends a fraction of the metric calls, and evaluates the next rules.
+	// The counters, timings, histograms and distributions are sent with their sample rate
+	// ("|@0.1"), so that the server scales them back up
+	RuleSample
+)
+
+// String returns the name of the action
+func (a RuleAction) String() string {
+	switch a {
+	case RuleAllow:
+		return "allow"
+	case RuleDrop:
+		return "drop"
+	case RuleRename:
+		return "rename"
+	case RuleSample:
+		return "sample"
+	}
+	return "unknown"
+}
+
+// Rule matches metric names with a glob pattern or a regular expression,
+// and applies an action to the matching metrics
+type Rule struct {
+	// Match is a glob pattern matching the whole name: "*" matches any characters but ".",
+	// "**" any characters, and "?" any character but "."
+	Match string
+	// Regexp is a regular expression matching the name, used instead of Match
+	Regexp string
+	Action RuleAction
+	// Replacement is the new name for RuleRename, where $1, $2... are the groups
+	// of the regular expression, or the wildcards of the glob pattern.
+	// Use ${1} when the group is followed by a letter, a digit or "_": "$1_x" is the group "1_x"
+	Replacement string
+	// SampleRate is the fraction of the calls to send for RuleSample, in (0, 1]
+	SampleRate float64
+}
+
+// compiledRule is a rule with its pattern compiled
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// globToRegexp converts a glob pattern to an anchored regular expression,
+// with a group for each wildcard
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString("(.*)")
+			i++
+		case '*' == glob[i]:
+			b.WriteString(`([^.]*)`)
+		case '?' == glob[i]:
+			b.WriteString(`([^.])`)
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+func compileRule(r Rule) (compiledRule, error) {
+	expr := r.Regexp
+	switch {
+	case "" != r.Match && "" != r.Regexp:
+		return compiledRule{}, errors.New("a rule can't have both Match and Regexp")
+	case "" != r.Match:
+		expr = globToRegexp(r.Match)
+	case "" == r.Regexp:
+		return compiledRule{}, errors.New("a rule needs Match or Regexp")
+	}
+	re, err := regexp.Compile(expr)
+	if nil != err {
+		return compiledRule{}, fmt.Errorf("invalid pattern: %s", err)
+	}
+	switch r.Action {
+	case RuleAllow, RuleDrop:
+	case RuleRename:
+		if "" == r.Replacement {
+			return compiledRule{}, errors.New("a rename rule needs a Replacement")
+		}
+	case RuleSample:
+		if r.SampleRate <= 0 || r.SampleRate > 1 {
+			return compiledRule{}, fmt.Errorf("invalid sample rate %g", r.SampleRate)
+		}
+	default:
+		return compiledRule{}, fmt.Errorf("unknown action %d", r.Action)
+	}
+	return compiledRule{Rule: r, re: re}, nil
+}
+
+// maxFilterDecisions is the number of metric names whose outcome is cached by a FilterClient
+const maxFilterDecisions = 10000
+
+// decision is the outcome of the rules for a metric name
+type decision struct {
+	name string
+	rate float64 // 0 if dropped
+}
+
+// FilterClient applies an ordered list of rules to the metrics, to drop, rename or sample them,
+// before sending them to another client. The rules apply to the metric functions
+// as well as to the events passed to SendEvents (which are renamed in place).
+// The metrics not dropped by any rule are sent.
+type FilterClient struct {
+	client Statsd
+	rules  []compiledRule
+
+	mu        sync.RWMutex
+	decisions map[string]decision
+}
+
+// NewFilterClient - Factory
+func NewFilterClient(client Statsd, rules ...Rule) (*FilterClient, error) {
+	f := &FilterClient{
+		client:    client,
+		decisions: make(map[string]decision),
+	}
+	for i, r := range rules {
+		c, err := compileRule(r)
+		if nil != err {
+			return nil, fmt.Errorf("statsd: rule %d: %s", i, err)
+		}
+		f.rules = append(f.rules, c)
+	}
+	return f, nil
+}
+
+// decide applies the rules to a metric name
+func (f *FilterClient) decide(name string) decision {
+	f.mu.RLock()
+	d, ok := f.decisions[name]
+	f.mu.RUnlock()
+	if ok {
+		return d
+	}
+
+	d = decision{name: name, rate: 1}
+rules:
+	for _, r := range f.rules {
+		if !r.re.MatchString(d.name) {
+			continue
+		}
+		switch r.Action {
+		case RuleAllow:
+			break rules
+		case RuleDrop:
+			d.rate = 0
+			break rules
+		case RuleRename:
+			d.name = r.re.ReplaceAllString(d.name, r.Replacement)
+		case RuleSample:
+			d.rate *= r.SampleRate
+		}
+	}
+
+	f.mu.Lock()
+	if len(f.decisions) >= maxFilterDecisions {
+		f.decisions = make(map[string]decision)
+	}
+	f.decisions[name] = d
+	f.mu.Unlock()
+	return d
+}
+
+// filter returns the name to send a metric as, and its sample rate,
+// or false if it's dropped (by a rule, or sampled out)
+func (f *FilterClient) filter(stat string) (string, float64, bool) {
+	d := f.decide(stat)
+	if d.rate <= 0 || (d.rate < 1 && rand.Float64() >= d.rate) {
+		return "", 0, false
+	}
+	return d.name, d.rate, true
+}
+
+// sendSampled sends a sampled call with its sample rate: with the format of the call
+// for a StatsdClient, as an event for the other clients
+func (f *FilterClient) sendSampled(e event.Event, format string, value interface{}, rate float64) error {
+	if c, ok := f.client.(*StatsdClient); ok {
+		return c.send(e.Key(), format, value, float32(rate), e.GetTags())
+	}
+	return f.client.SendEvents(map[string]event.Event{e.Key(): &sampledEvent{Event: e, rate: rate}})
+}
+
+// CreateSocket creates a UDP connection with the underlying client
+func (f *FilterClient) CreateSocket() error {
+	return f.client.CreateSocket()
+}
+
+// CreateTCPSocket creates a TCP connection with the underlying client
+func (f *FilterClient) CreateTCPSocket() error {
+	return f.client.CreateTCPSocket()
+}
+
+// Close the underlying client
+func (f *FilterClient) Close() error {
+	return f.client.Close()
+}
+
+// Incr - Increment a counter metric. Often used to note a particular event
+func (f *FilterClient) Incr(stat string, count int64, tags ...string) error {
+	name, rate, ok := f.filter(stat)
+	if !ok {
+		return nil
+	}
+	if rate < 1 {
+		if err := checkCount(count); nil != err {
+			return err
+		}
+		return f.sendSampled(&event.Increment{Name: name, Value: count, Tags: tags}, "%d|c", count, rate)
+	}
+	return f.client.Incr(name, count, tags...)
+}
+
+// Decr - Decrement a counter metric. Often used to note a particular event
+func (f *FilterClient) Decr(stat string, count int64, tags ...string) error {
+	name, rate, ok := f.filter(stat)
+	if !ok {
+		return nil
+	}
+	if rate < 1 {
+		if err := checkCount(count); nil != err {
+			return err
+		}
+		return f.sendSampled(&event.Increment{Name: name, Value: -count, Tags: tags}, "%d|c", -count, rate)
+	}
+	return f.client.Decr(name, count, tags...)
+}
+
+// Timing - Track a duration event
+// the time delta must be given in milliseconds
+func (f *FilterClient) Timing(stat string, delta int64, tags ...string) error {
+	name, rate, ok := f.filter(stat)
+	if !ok {
+		return nil
+	}
+	if rate < 1 {
+		return f.sendSampled(event.NewTiming(name, delta, tags...), "%d|ms", delta, rate)
+	}
+	return f.client.Timing(name, delta, tags...)
+}
+
+// PrecisionTiming - Track a duration event
+// the time delta has to be a duration
+func (f *FilterClient) PrecisionTiming(stat string, delta time.Duration, tags ...string) error {
+	name, rate, ok := f.filter(stat)
+	if !ok {
+		return nil
+	}
+	if rate < 1 {
+		ms := float64(delta) / float64(time.Millisecond)
+		return f.sendSampled(event.NewPrecisionTiming(name, delta, tags...), "%.6f|ms", ms, rate)
+	}
+	return f.client.PrecisionTiming(name, delta, tags...)
+}
+
+// Gauge - Gauges are a constant data type. They are not subject to averaging,
+// and they don’t change unless you change them. That is, once you set a gauge value,
+// it will be a flat line on the graph until you change it again
+func (f *FilterClient) Gauge(stat string, value int64, tags ...string) error {
+	name, _, ok := f.filter(stat)
+	if !ok {
+		return nil
+	}
+	return f.client.Gauge(name, value, tags...)
+}
+
+// GaugeDelta records a delta from the previous value (as int64)
+func (f *FilterClient) GaugeDelta(stat string, value int64, tags ...string) error {
+	name, _, ok := f.filter(stat)
+	if !ok {
+		return nil
+	}
+	return f.client.GaugeDelta(name, value, tags...)
+}
+
+// FGauge is a Gauge working with float64 values
+func (f *FilterClient) FGauge(stat string, value float64, tags ...string) error {
+	name, _, ok := f.filter(stat)
+	if !ok {
+		return nil
+	}
+	return f.client.FGauge(name, value, tags...)
+}
+
+// FGaugeDelta records a delta from the previous value (as float64)
+func (f *FilterClient) FGaugeDelta(stat string, value float64, tags ...string) error {
+	name, _, ok := f.filter(stat)
+	if !ok {
+		return nil
+	}
+	return f.client.FGaugeDelta(name, value, tags...)
+}
+
+// Absolute - Send absolute-valued metric (not averaged/aggregated)
+func (f *FilterClient) Absolute(stat string, value int64, tags ...string) error {
+	name, _, ok := f.filter(stat)
+	if !ok {
+		return nil
+	}
+	return f.client.Absolute(name, value, tags...)
+}
+
+// FAbsolute - Send absolute-valued floating point metric (not averaged/aggregated)
+func (f *FilterClient) FAbsolute(stat string, value float64, tags ...string) error {
+	name, _, ok := f.filter(stat)
+	if !ok {
+		return nil
+	}
+	return f.client.FAbsolute(name, value, tags...)
+}
+
+// Total - Send a metric that is continously increasing, e.g. read operations since boot
+func (f *FilterClient) Total(stat string, value int64, tags ...string) error {
+	name, _, ok := f.filter(stat)
+	if !ok {
+		return nil
+	}
+	return f.client.Total(name, value, tags...)
+}
+
+// Set - Send a value to be counted as unique per flush interval (e.g. unique users)
+func (f *FilterClient) Set(stat string, value string, tags ...string) error {
+	name, _, ok := f.filter(stat)
+	if !ok {
+		return nil
+	}
+	return f.client.Set(name, value, tags...)
+}
+
+// Histogram - Send a value to be aggregated into a statistical distribution by the server (per host)
+func (f *FilterClient) Histogram(stat string, value float64, tags ...string) error {
+	name, rate, ok := f.filter(stat)
+	if !ok {
+		return nil
+	}
+	if rate < 1 {
+		return f.sendSampled(event.NewHistogram(name, value, tags...), "%g|h", value, rate)
+	}
+	return f.client.Histogram(name, value, tags...)
+}
+
+// Distribution - Send a value to be aggregated into a statistical distribution
+// by the server (globally, across all hosts)
+func (f *FilterClient) Distribution(stat string, value float64, tags ...string) error {
+	name, rate, ok := f.filter(stat)
+	if !ok {
+		return nil
+	}
+	if rate < 1 {
+		return f.sendSampled(event.NewDistribution(name, value, tags...), "%g|d", value, rate)
+	}
+	return f.client.Distribution(name, value, tags...)
+}
+
+// SendEvents - Sends stats from all the event objects not dropped by the rules.
+// Renamed copies of the renamed events are sent (the events are not modified),
+// and the sampled ones are sent with their sample rate.
+func (f *FilterClient) SendEvents(events map[string]event.Event) error {
+	filtered := make(map[string]event.Event, len(events))
+	for k, e := range events {
+		name, rate, ok := f.filter(e.Key())
+		if !ok {
+			continue
+		}
+		if name != e.Key() {
+			e = renamedCopy(e, name, e.GetTags())
+		}
+		if rate < 1 {
+			e = &sampledEvent{Event: e, rate: rate}
+		}
+		filtered[k] = e
+	}
+	if 0 == len(filtered) {
+		return nil
+	}
+	return f.client.SendEvents(filtered)
+}
+
+// sampledEvent is an event sent with a sample rate: "|@rate" is added to its counter, timing,
+// histogram and distribution lines (the other types of metrics are not scaled by the servers).
+// It's aggregated like the event it wraps.
+type sampledEvent struct {
+	event.Event
+	rate float64
+}
+
+// Stats returns the lines of the event, with the sample rate
+func (e sampledEvent) Stats() []string {
+	stats := e.Event.Stats()
+	ret := make([]string, 0, len(stats))
+	for _, line := range stats {
+		ret = append(ret, addSampleRate(line, e.rate))
+	}
+	return ret
+}
+
+// addSampleRate adds the sample rate to a line, before its tags, or multiplies it
+// with the sample rate of the line (e.g. a histogram with more values than it keeps)
+func addSampleRate(line string, rate float64) string {
+	i := strings.IndexByte(line, '|')
+	if i < 0 {
+		return line
+	}
+	sections := strings.Split(line[i+1:], "|")
+	switch sections[0] {
+	case "c", "ms", "h", "d":
+	default:
+		return line
+	}
+	for j, section := range sections[1:] {
+		if strings.HasPrefix(section, "@") {
+			if r, err := strconv.ParseFloat(section[1:], 64); nil == err {
+				sections[j+1] = "@" + strconv.FormatFloat(r*rate, 'f', 6, 64)
+				return line[:i+1] + strings.Join(sections, "|")
+			}
+		}
+	}
+	sampled := append([]string{sections[0], "@" + strconv.FormatFloat(rate, 'f', 6, 64)}, sections[1:]...)
+	return line[:i+1] + strings.Join(sampled, "|")
+}
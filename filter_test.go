@@ -0,0 +1,239 @@
+package statsd
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/quipo/statsd/event"
+	"github.com/quipo/statsd/statsdtest"
+)
+
+var _ Statsd = (*FilterClient)(nil)
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob    string
+		name    string
+		matches bool
+	}{
+		{"api.*.latency", "api.users.latency", true},
+		{"api.*.latency", "api.users.get.latency", false},
+		{"api.**", "api.users.get.latency", true},
+		{"api.**", "apix.users", false},
+		{"db.shard?", "db.shard1", true},
+		{"db.shard?", "db.shard12", false},
+		{"a+b.*", "a+b.c", true},
+	}
+	for _, tt := range tests {
+		c, err := compileRule(Rule{Match: tt.glob, Action: RuleDrop})
+		if nil != err {
+			t.Fatal(err)
+		}
+		if actual := c.re.MatchString(tt.name); tt.matches != actual {
+			t.Errorf("%s ~ %s: Expected: %v, Actual: %v", tt.glob, tt.name, tt.matches, actual)
+		}
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	rules := []Rule{
+		{Action: RuleDrop},
+		{Match: "a.*", Regexp: "^a", Action: RuleDrop},
+		{Regexp: "(", Action: RuleDrop},
+		{Match: "a.*", Action: RuleRename},
+		{Match: "a.*", Action: RuleSample, SampleRate: 0},
+		{Match: "a.*", Action: RuleSample, SampleRate: 1.5},
+		{Match: "a.*", Action: RuleAction(42)},
+	}
+	for _, r := range rules {
+		if _, err := NewFilterClient(NoopClient{}, r); nil == err {
+			t.Errorf("expected an error for the rule %+v", r)
+		}
+	}
+}
+
+func TestFilterClient(t *testing.T) {
+	srv, err := statsdtest.NewServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	client := NewStatsdClient(srv.Addr, "test.")
+	filtered, err := NewFilterClient(client,
+		Rule{Match: "debug.**", Action: RuleDrop},
+		Rule{Match: "legacy.*.hits", Action: RuleRename, Replacement: "cache.$1.hits"},
+		Rule{Regexp: `^cache\.`, Action: RuleAllow},
+		Rule{Match: "**", Action: RuleDrop},
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err = filtered.CreateSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer filtered.Close()
+
+	filtered.Incr("debug.loop", 1)
+	filtered.Incr("legacy.users.hits", 2, "env:prod")
+	filtered.Gauge("cache.size", 10)
+	filtered.Timing("other.latency", 5)
+	if err = srv.WaitFor(2); nil != err {
+		t.Fatal(err)
+	}
+	srv.AssertCounter(t, "test.cache.users.hits", 2, "env:prod")
+	srv.AssertGauge(t, "test.cache.size", 10)
+	srv.AssertNotReceived(t, "test.debug.loop", "c")
+	srv.AssertNotReceived(t, "test.other.latency", "ms")
+}
+
+func TestFilterClientSendEvents(t *testing.T) {
+	client := &flakyClient{}
+	filtered, err := NewFilterClient(client,
+		Rule{Match: "debug.**", Action: RuleDrop},
+		Rule{Match: "old_*", Action: RuleRename, Replacement: "new_$1"},
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	inc := &event.Increment{Name: "old_hits", Value: 3}
+	filtered.SendEvents(map[string]event.Event{
+		"debug.x":  &event.Increment{Name: "debug.x", Value: 1},
+		"old_hits": inc,
+		"size":     &event.Gauge{Name: "size", Value: 5},
+	})
+	sort.Strings(client.lines)
+	expected := []string{"new_hits:3|c", "size:5|g"}
+	if len(expected) != len(client.lines) || expected[0] != client.lines[0] || expected[1] != client.lines[1] {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, client.lines, client.lines)
+	}
+	if "old_hits" != inc.Name {
+		t.Errorf("the original event was renamed: %s", inc.Name)
+	}
+}
+
+func TestFilterClientSendEventsTwice(t *testing.T) {
+	client := &flakyClient{}
+	filtered, err := NewFilterClient(client, Rule{Match: "old.*", Action: RuleRename, Replacement: "new.$1"})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// e.g. a StatsdBuffer sending its events again after a failed flush
+	events := map[string]event.Event{
+		"req":    &event.Increment{Name: "old.req", Value: 1, Tags: []string{"env:prod"}},
+		"custom": &customEvent{name: "old.custom", value: 2},
+	}
+	filtered.SendEvents(events)
+	filtered.SendEvents(events)
+	sort.Strings(client.lines)
+	expected := []string{"new.custom:2|c", "new.custom:2|c", "new.req:1|c|#env:prod", "new.req:1|c|#env:prod"}
+	if strings.Join(expected, ",") != strings.Join(client.lines, ",") {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, client.lines, client.lines)
+	}
+}
+
+func TestFilterClientSample(t *testing.T) {
+	client := &flakyClient{}
+	filtered, err := NewFilterClient(client,
+		Rule{Match: "noisy.*", Action: RuleSample, SampleRate: 0.5},
+		Rule{Match: "noisy.hits", Action: RuleSample, SampleRate: 0.5},
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// the sample rates of the matching rules are multiplied
+	if d := filtered.decide("noisy.hits"); 0.25 != d.rate {
+		t.Errorf("unexpected sample rate %v", d.rate)
+	}
+	inc := &event.Increment{Name: "noisy.hits", Value: 3, Tags: []string{"env:prod"}}
+	sent := 0
+	for i := 0; i < 1000; i++ {
+		client.lines = nil
+		filtered.SendEvents(map[string]event.Event{"noisy.hits": inc})
+		if 1 == len(client.lines) {
+			sent++
+			if "noisy.hits:3|c|@0.250000|#env:prod" != client.lines[0] {
+				t.Fatalf("the sample rate was not sent: %s", client.lines[0])
+			}
+		}
+	}
+	if sent < 150 || sent > 350 {
+		t.Errorf("unexpected number of sampled events: %d", sent)
+	}
+	if 3 != inc.Value {
+		t.Errorf("the original event was modified: %d", inc.Value)
+	}
+
+	// direct calls, to a client which is not a StatsdClient
+	client.lines = nil
+	for 0 == len(client.lines) {
+		filtered.Histogram("noisy.size", 2.5)
+	}
+	if "noisy.size:2.5|h|@0.500000" != client.lines[0] {
+		t.Errorf("the sample rate was not sent: %s", client.lines[0])
+	}
+}
+
+func TestFilterClientSampleRate(t *testing.T) {
+	client, err := NewStatsdClientWithOptions("127.0.0.1:8125", "p.", WithPayloadSize(1432))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	mock := &MockNetConn{}
+	client.conn = mock
+	filtered, err := NewFilterClient(client, Rule{Match: "db.*", Action: RuleSample, SampleRate: 0.3})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// sent with the format of the call and the sample rate, not scaled
+	for 0 == mock.buf.Len() {
+		filtered.Timing("db.query", 5, "env:prod")
+	}
+	if expected := "p.db.query:5|ms|@0.300000|#env:prod"; expected != strings.TrimSpace(mock.buf.String()) {
+		t.Errorf("unexpected metric: Expected: %q, Actual: %q", expected, mock.buf.String())
+	}
+	mock.buf.Reset()
+	for 0 == mock.buf.Len() {
+		filtered.Incr("db.rows", 1)
+	}
+	if expected := "p.db.rows:1|c|@0.300000"; expected != strings.TrimSpace(mock.buf.String()) {
+		t.Errorf("unexpected metric: Expected: %q, Actual: %q", expected, mock.buf.String())
+	}
+}
+
+func TestAddSampleRate(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"a:1|c", "a:1|c|@0.500000"},
+		{"a:1|c|#env:prod", "a:1|c|@0.500000|#env:prod"},
+		{"a.avg:5|ms", "a.avg:5|ms|@0.500000"},
+		{"a:2.5|h|@0.500000", "a:2.5|h|@0.250000"},
+		{"a:5|g", "a:5|g"},
+		{"a:u1|s|#env:prod", "a:u1|s|#env:prod"},
+	}
+	for _, tt := range tests {
+		if actual := addSampleRate(tt.line, 0.5); tt.expected != actual {
+			t.Errorf("%s: Expected: %s, Actual: %s", tt.line, tt.expected, actual)
+		}
+	}
+}
+
+func TestRenameGroupFollowedByLetter(t *testing.T) {
+	filtered, err := NewFilterClient(NoopClient{},
+		Rule{Match: "old.*", Action: RuleRename, Replacement: "new.${1}_total"},
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if d := filtered.decide("old.hits"); "new.hits_total" != d.name {
+		t.Errorf("unexpected name: %s", d.name)
+	}
+}
@@ -0,0 +1,334 @@
+package statsd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/quipo/statsd/event"
+)
+
+// Method identifies the Statsd function a Metric was sent with
+type Method int
+
+// metric methods
+const (
+	MethodIncr Method = iota
+	MethodDecr
+	MethodTiming
+	MethodPrecisionTiming
+	MethodGauge
+	MethodGaugeDelta
+	MethodFGauge
+	MethodFGaugeDelta
+	MethodAbsolute
+	MethodFAbsolute
+	MethodTotal
+	MethodSet
+	MethodHistogram
+	MethodDistribution
+	MethodSendEvents // the events of SendEvents without a matching function, e.g. custom events
+)
+
+var methodNames = [...]string{
+	MethodIncr:            "Incr",
+	MethodDecr:            "Decr",
+	MethodTiming:          "Timing",
+	MethodPrecisionTiming: "PrecisionTiming",
+	MethodGauge:           "Gauge",
+	MethodGaugeDelta:      "GaugeDelta",
+	MethodFGauge:          "FGauge",
+	MethodFGaugeDelta:     "FGaugeDelta",
+	MethodAbsolute:        "Absolute",
+	MethodFAbsolute:       "FAbsolute",
+	MethodTotal:           "Total",
+	MethodSet:             "Set",
+	MethodHistogram:       "Histogram",
+	MethodDistribution:    "Distribution",
+	MethodSendEvents:      "SendEvents",
+}
+
+// String returns the name of the Statsd function
+func (m Method) String() string {
+	if m >= 0 && int(m) < len(methodNames) {
+		return methodNames[m]
+	}
+	return fmt.Sprintf("Method(%d)", int(m))
+}
+
+// Metric is a Statsd call passed through the interceptors.
+// Value is an int64 for Incr, Decr, Timing, Gauge, GaugeDelta, Absolute and Total,
+// a float64 for FGauge, FGaugeDelta, FAbsolute, Histogram and Distribution,
+// a time.Duration for PrecisionTiming and a string for Set.
+// Each event passed to SendEvents is a Metric too, with the Method matching its type,
+// its name and tags, and the event in Event: Value is its Payload() (changing it has
+// no effect), and the event is sent with the Name and Tags of the Metric.
+type Metric struct {
+	Method Method
+	Name   string
+	Value  interface{}
+	Tags   []string
+	Event  event.Event
+
+	// where the events of SendEvents are collected once through the chain
+	events map[string]event.Event
+	key    string
+}
+
+// Interceptor is called for each metric, and calls next to pass it (possibly modified)
+// to the next interceptor, and eventually to the client. Not calling next drops the metric.
+type Interceptor func(m *Metric, next func(*Metric) error) error
+
+// InterceptedClient passes the metrics through a chain of interceptors
+// before sending them to another client
+type InterceptedClient struct {
+	client Statsd
+	chain  func(*Metric) error
+}
+
+// NewInterceptedClient - Factory. The interceptors are called in the given order.
+func NewInterceptedClient(client Statsd, interceptors ...Interceptor) *InterceptedClient {
+	c := &InterceptedClient{client: client}
+	c.chain = c.send
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], c.chain
+		c.chain = func(m *Metric) error {
+			return interceptor(m, next)
+		}
+	}
+	return c
+}
+
+// send calls the function of the client matching the metric
+func (c *InterceptedClient) send(m *Metric) error {
+	if nil != m.Event {
+		e := m.Event
+		if m.Name != e.Key() || !sameTags(m.Tags, e.GetTags()) {
+			e = renamedCopy(e, m.Name, m.Tags)
+		}
+		m.events[m.key] = e
+		return nil
+	}
+	var ok bool
+	switch m.Method {
+	case MethodIncr, MethodDecr, MethodTiming, MethodGauge, MethodGaugeDelta, MethodAbsolute, MethodTotal:
+		var value int64
+		if value, ok = m.Value.(int64); ok {
+			return c.sendInt(m, value)
+		}
+	case MethodFGauge, MethodFGaugeDelta, MethodFAbsolute, MethodHistogram, MethodDistribution:
+		var value float64
+		if value, ok = m.Value.(float64); ok {
+			return c.sendFloat(m, value)
+		}
+	case MethodPrecisionTiming:
+		var value time.Duration
+		if value, ok = m.Value.(time.Duration); ok {
+			return c.client.PrecisionTiming(m.Name, value, m.Tags...)
+		}
+	case MethodSet:
+		var value string
+		if value, ok = m.Value.(string); ok {
+			return c.client.Set(m.Name, value, m.Tags...)
+		}
+	default:
+		return fmt.Errorf("statsd: unknown method %s for %s", m.Method, m.Name)
+	}
+	return fmt.Errorf("statsd: invalid value %T for %s %s", m.Value, m.Method, m.Name)
+}
+
+func (c *InterceptedClient) sendInt(m *Metric, value int64) error {
+	switch m.Method {
+	case MethodIncr:
+		return c.client.Incr(m.Name, value, m.Tags...)
+	case MethodDecr:
+		return c.client.Decr(m.Name, value, m.Tags...)
+	case MethodTiming:
+		return c.client.Timing(m.Name, value, m.Tags...)
+	case MethodGauge:
+		return c.client.Gauge(m.Name, value, m.Tags...)
+	case MethodGaugeDelta:
+		return c.client.GaugeDelta(m.Name, value, m.Tags...)
+	case MethodAbsolute:
+		return c.client.Absolute(m.Name, value, m.Tags...)
+	}
+	return c.client.Total(m.Name, value, m.Tags...)
+}
+
+func (c *InterceptedClient) sendFloat(m *Metric, value float64) error {
+	switch m.Method {
+	case MethodFGauge:
+		return c.client.FGauge(m.Name, value, m.Tags...)
+	case MethodFGaugeDelta:
+		return c.client.FGaugeDelta(m.Name, value, m.Tags...)
+	case MethodFAbsolute:
+		return c.client.FAbsolute(m.Name, value, m.Tags...)
+	case MethodHistogram:
+		return c.client.Histogram(m.Name, value, m.Tags...)
+	}
+	return c.client.Distribution(m.Name, value, m.Tags...)
+}
+
+// CreateSocket creates a UDP connection with the underlying client
+func (c *InterceptedClient) CreateSocket() error {
+	return c.client.CreateSocket()
+}
+
+// CreateTCPSocket creates a TCP connection with the underlying client
+func (c *InterceptedClient) CreateTCPSocket() error {
+	return c.client.CreateTCPSocket()
+}
+
+// Close the underlying client
+func (c *InterceptedClient) Close() error {
+	return c.client.Close()
+}
+
+// Incr - Increment a counter metric. Often used to note a particular event
+func (c *InterceptedClient) Incr(stat string, count int64, tags ...string) error {
+	return c.chain(&Metric{Method: MethodIncr, Name: stat, Value: count, Tags: tags})
+}
+
+// Decr - Decrement a counter metric. Often used to note a particular event
+func (c *InterceptedClient) Decr(stat string, count int64, tags ...string) error {
+	return c.chain(&Metric{Method: MethodDecr, Name: stat, Value: count, Tags: tags})
+}
+
+// Timing - Track a duration event
+// the time delta must be given in milliseconds
+func (c *InterceptedClient) Timing(stat string, delta int64, tags ...string) error {
+	return c.chain(&Metric{Method: MethodTiming, Name: stat, Value: delta, Tags: tags})
+}
+
+// PrecisionTiming - Track a duration event
+// the time delta has to be a duration
+func (c *InterceptedClient) PrecisionTiming(stat string, delta time.Duration, tags ...string) error {
+	return c.chain(&Metric{Method: MethodPrecisionTiming, Name: stat, Value: delta, Tags: tags})
+}
+
+// Gauge - Gauges are a constant data type. They are not subject to averaging,
+// and they don’t change unless you change them. That is, once you set a gauge value,
+// it will be a flat line on the graph until you change it again
+func (c *InterceptedClient) Gauge(stat string, value int64, tags ...string) error {
+	return c.chain(&Metric{Method: MethodGauge, Name: stat, Value: value, Tags: tags})
+}
+
+// GaugeDelta records a delta from the previous value (as int64)
+func (c *InterceptedClient) GaugeDelta(stat string, value int64, tags ...string) error {
+	return c.chain(&Metric{Method: MethodGaugeDelta, Name: stat, Value: value, Tags: tags})
+}
+
+// FGauge is a Gauge working with float64 values
+func (c *InterceptedClient) FGauge(stat string, value float64, tags ...string) error {
+	return c.chain(&Metric{Method: MethodFGauge, Name: stat, Value: value, Tags: tags})
+}
+
+// FGaugeDelta records a delta from the previous value (as float64)
+func (c *InterceptedClient) FGaugeDelta(stat string, value float64, tags ...string) error {
+	return c.chain(&Metric{Method: MethodFGaugeDelta, Name: stat, Value: value, Tags: tags})
+}
+
+// Absolute - Send absolute-valued metric (not averaged/aggregated)
+func (c *InterceptedClient) Absolute(stat string, value int64, tags ...string) error {
+	return c.chain(&Metric{Method: MethodAbsolute, Name: stat, Value: value, Tags: tags})
+}
+
+// FAbsolute - Send absolute-valued floating point metric (not averaged/aggregated)
+func (c *InterceptedClient) FAbsolute(stat string, value float64, tags ...string) error {
+	return c.chain(&Metric{Method: MethodFAbsolute, Name: stat, Value: value, Tags: tags})
+}
+
+// Total - Send a metric that is continously increasing, e.g. read operations since boot
+func (c *InterceptedClient) Total(stat string, value int64, tags ...string) error {
+	return c.chain(&Metric{Method: MethodTotal, Name: stat, Value: value, Tags: tags})
+}
+
+// Set - Send a value to be counted as unique per flush interval (e.g. unique users)
+func (c *InterceptedClient) Set(stat string, value string, tags ...string) error {
+	return c.chain(&Metric{Method: MethodSet, Name: stat, Value: value, Tags: tags})
+}
+
+// Histogram - Send a value to be aggregated into a statistical distribution by the server (per host)
+func (c *InterceptedClient) Histogram(stat string, value float64, tags ...string) error {
+	return c.chain(&Metric{Method: MethodHistogram, Name: stat, Value: value, Tags: tags})
+}
+
+// Distribution - Send a value to be aggregated into a statistical distribution
+// by the server (globally, across all hosts)
+func (c *InterceptedClient) Distribution(stat string, value float64, tags ...string) error {
+	return c.chain(&Metric{Method: MethodDistribution, Name: stat, Value: value, Tags: tags})
+}
+
+// SendEvents - Sends stats from all the event objects through the interceptors, one Metric
+// per event, then sends the events passed on by the interceptors together.
+// The events are not modified: the ones renamed or tagged by the interceptors are copied.
+func (c *InterceptedClient) SendEvents(events map[string]event.Event) error {
+	passed := make(map[string]event.Event, len(events))
+	var ret error
+	for k, e := range events {
+		m := &Metric{
+			Method: eventMethod(e),
+			Name:   e.Key(),
+			Value:  e.Payload(),
+			Tags:   append([]string(nil), e.GetTags()...), // appending to them must not change the event
+			Event:  e,
+			events: passed,
+			key:    k,
+		}
+		if err := c.chain(m); nil != err && nil == ret {
+			ret = err
+		}
+	}
+	if 0 == len(passed) {
+		return ret
+	}
+	if err := c.client.SendEvents(passed); nil != err {
+		return err
+	}
+	return ret
+}
+
+// eventMethod returns the Statsd function matching the type of an event
+func eventMethod(e event.Event) Method {
+	switch e.(type) {
+	case *event.Increment:
+		return MethodIncr
+	case *event.Timing:
+		return MethodTiming
+	case *event.PrecisionTiming:
+		return MethodPrecisionTiming
+	case *event.Gauge:
+		return MethodGauge
+	case *event.GaugeDelta:
+		return MethodGaugeDelta
+	case *event.FGauge:
+		return MethodFGauge
+	case *event.FGaugeDelta:
+		return MethodFGaugeDelta
+	case *event.Absolute:
+		return MethodAbsolute
+	case *event.FAbsolute:
+		return MethodFAbsolute
+	case *event.Total:
+		return MethodTotal
+	case *event.Set:
+		return MethodSet
+	case *event.Histogram:
+		return MethodHistogram
+	case *event.Distribution:
+		return MethodDistribution
+	}
+	return MethodSendEvents
+}
+
+// sameTags tells whether two lists of tags are the same, in the same order
+func sameTags(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+package statsd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quipo/statsd/event"
+	"github.com/quipo/statsd/statsdtest"
+)
+
+var _ Statsd = (*InterceptedClient)(nil)
+
+func TestInterceptedClient(t *testing.T) {
+	srv, err := statsdtest.NewServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var calls []string
+	logging := func(m *Metric, next func(*Metric) error) error {
+		calls = append(calls, m.Method.String()+" "+m.Name)
+		return next(m)
+	}
+	tagging := func(m *Metric, next func(*Metric) error) error {
+		m.Tags = append(m.Tags, "env:prod")
+		return next(m)
+	}
+	validating := func(m *Metric, next func(*Metric) error) error {
+		if strings.HasPrefix(m.Name, "debug.") {
+			return nil
+		}
+		return next(m)
+	}
+	client := NewInterceptedClient(NewStatsdClient(srv.Addr, "test."), logging, tagging, validating)
+	if err = client.CreateSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	client.Incr("req", 1)
+	client.Gauge("debug.loop", 3)
+	client.FGauge("load", 0.5, "cpu:0")
+	client.PrecisionTiming("db", 2*time.Millisecond)
+	client.Set("users", "u1")
+	if err = srv.WaitFor(4); nil != err {
+		t.Fatal(err)
+	}
+	srv.AssertCounter(t, "test.req", 1, "env:prod")
+	srv.AssertGauge(t, "test.load", 0.5, "cpu:0", "env:prod")
+	srv.AssertReceived(t, "test.db", "ms", "env:prod")
+	srv.AssertReceived(t, "test.users", "s", "env:prod")
+	srv.AssertNotReceived(t, "test.debug.loop", "g")
+
+	expected := []string{"Incr req", "Gauge debug.loop", "FGauge load", "PrecisionTiming db", "Set users"}
+	if strings.Join(expected, ",") != strings.Join(calls, ",") {
+		t.Errorf("unexpected calls: Expected: %v, Actual: %v", expected, calls)
+	}
+}
+
+func TestInterceptedClientSendEvents(t *testing.T) {
+	client := &flakyClient{}
+	var calls []string
+	intercepted := NewInterceptedClient(client, func(m *Metric, next func(*Metric) error) error {
+		calls = append(calls, fmt.Sprintf("%s %s %v", m.Method, m.Name, m.Value))
+		if strings.HasPrefix(m.Name, "debug.") {
+			return nil
+		}
+		m.Name = "app." + m.Name
+		m.Tags = append(m.Tags, "env:prod")
+		return next(m)
+	})
+	hits := &event.Increment{Name: "hits", Value: 2, Tags: []string{"route:/api"}}
+	custom := &customEvent{name: "custom", value: 4}
+	intercepted.SendEvents(map[string]event.Event{
+		"hits":   hits,
+		"custom": custom,
+		"debug":  &event.Gauge{Name: "debug.loop", Value: 1},
+	})
+
+	sort.Strings(calls)
+	expected := []string{"Gauge debug.loop 1", "Incr hits 2", "SendEvents custom 4"}
+	if strings.Join(expected, ",") != strings.Join(calls, ",") {
+		t.Errorf("unexpected calls: Expected: %v, Actual: %v", expected, calls)
+	}
+	sort.Strings(client.lines)
+	expected = []string{"app.custom:4|c|#env:prod", "app.hits:2|c|#route:/api,env:prod"}
+	if strings.Join(expected, ",") != strings.Join(client.lines, ",") {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, client.lines, client.lines)
+	}
+	if "hits" != hits.Name || 1 != len(hits.Tags) || "custom" != custom.name {
+		t.Errorf("the events were modified: %v %v", hits, custom)
+	}
+}
+
+func TestBufferedInterceptedClient(t *testing.T) {
+	client := &flakyClient{}
+	var names []string
+	intercepted := NewInterceptedClient(client, func(m *Metric, next func(*Metric) error) error {
+		names = append(names, m.Name)
+		m.Tags = append(m.Tags, "env:prod")
+		return next(m)
+	})
+	buffered := NewStatsdBuffer(time.Hour, intercepted)
+	buffered.Verbose = false
+	buffered.Incr("req", 1)
+	buffered.Incr("req", 2)
+	buffered.Gauge("queue", 5)
+	if err := buffered.Close(); nil != err {
+		t.Fatal(err)
+	}
+
+	// the interceptors see every aggregated metric of the flush
+	sort.Strings(names)
+	if expected := []string{"queue", "req"}; strings.Join(expected, ",") != strings.Join(names, ",") {
+		t.Errorf("unexpected metrics intercepted: Expected: %v, Actual: %v", expected, names)
+	}
+	sort.Strings(client.lines)
+	expected := []string{"queue:5|g|#env:prod", "req:3|c|#env:prod"}
+	if strings.Join(expected, ",") != strings.Join(client.lines, ",") {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, client.lines, client.lines)
+	}
+}
+
+func TestInterceptedClientInvalidValue(t *testing.T) {
+	client := NewInterceptedClient(NoopClient{}, func(m *Metric, next func(*Metric) error) error {
+		m.Value = float64(1)
+		return next(m)
+	})
+	if err := client.Incr("req", 1); nil == err {
+		t.Error("expected an error for a float64 counter")
+	}
+	if err := client.FGauge("load", 1); nil != err {
+		t.Error(err)
+	}
+}
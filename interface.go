@@ -11,18 +11,22 @@ type Statsd interface {
 	CreateSocket() error
 	CreateTCPSocket() error
 	Close() error
-	Incr(stat string, count int64) error
-	Decr(stat string, count int64) error
-	Timing(stat string, delta int64) error
-	PrecisionTiming(stat string, delta time.Duration) error
-	Gauge(stat string, value int64) error
-	GaugeDelta(stat string, value int64) error
-	Absolute(stat string, value int64) error
-	Total(stat string, value int64) error
+	Incr(stat string, count int64, tags ...string) error
+	Decr(stat string, count int64, tags ...string) error
+	Timing(stat string, delta int64, tags ...string) error
+	PrecisionTiming(stat string, delta time.Duration, tags ...string) error
+	Gauge(stat string, value int64, tags ...string) error
+	GaugeDelta(stat string, value int64, tags ...string) error
+	Absolute(stat string, value int64, tags ...string) error
+	Total(stat string, value int64, tags ...string) error
 
-	FGauge(stat string, value float64) error
-	FGaugeDelta(stat string, value float64) error
-	FAbsolute(stat string, value float64) error
+	FGauge(stat string, value float64, tags ...string) error
+	FGaugeDelta(stat string, value float64, tags ...string) error
+	FAbsolute(stat string, value float64, tags ...string) error
+
+	Set(stat string, value string, tags ...string) error
+	Histogram(stat string, value float64, tags ...string) error
+	Distribution(stat string, value float64, tags ...string) error
 
 	SendEvents(events map[string]event.Event) error
 }
@@ -22,9 +22,10 @@ import (
 )
 
 type statelessStatsdFunction func() error
-type intMetricStatsdFunction func(string, int64) error
-type floatMetricStatsdFunction func(string, float64) error
-type durationMetricStatsdFunction func(string, time.Duration) error
+type intMetricStatsdFunction func(string, int64, ...string) error
+type floatMetricStatsdFunction func(string, float64, ...string) error
+type durationMetricStatsdFunction func(string, time.Duration, ...string) error
+type stringMetricStatsdFunction func(string, string, ...string) error
 type eventsStatsdFunction func(events map[string]event.Event) error
 
 // MockStatsdClient at its simplest provides a layer of indirection so that
@@ -54,6 +55,10 @@ type MockStatsdClient struct {
 	FGaugeDeltaFn floatMetricStatsdFunction
 	FAbsoluteFn   floatMetricStatsdFunction
 
+	SetFn          stringMetricStatsdFunction
+	HistogramFn    floatMetricStatsdFunction
+	DistributionFn floatMetricStatsdFunction
+
 	SendEventsFn eventsStatsdFunction
 }
 
@@ -80,81 +85,102 @@ func (msc *MockStatsdClient) Close() error {
 	return msc.CloseFn()
 }
 
-func (msc *MockStatsdClient) Incr(stat string, count int64) error {
+func (msc *MockStatsdClient) Incr(stat string, count int64, tags ...string) error {
 	if msc.IncrFn == nil {
 		return nil
 	}
-	return msc.IncrFn(stat, count)
+	return msc.IncrFn(stat, count, tags...)
 }
 
-func (msc *MockStatsdClient) Decr(stat string, count int64) error {
+func (msc *MockStatsdClient) Decr(stat string, count int64, tags ...string) error {
 	if msc.DecrFn == nil {
 		return nil
 	}
-	return msc.DecrFn(stat, count)
+	return msc.DecrFn(stat, count, tags...)
 }
 
-func (msc *MockStatsdClient) Timing(stat string, delta int64) error {
+func (msc *MockStatsdClient) Timing(stat string, delta int64, tags ...string) error {
 	if msc.TimingFn == nil {
 		return nil
 	}
-	return msc.TimingFn(stat, delta)
+	return msc.TimingFn(stat, delta, tags...)
 }
 
-func (msc *MockStatsdClient) PrecisionTiming(stat string, delta time.Duration) error {
+func (msc *MockStatsdClient) PrecisionTiming(stat string, delta time.Duration, tags ...string) error {
 	if msc.PrecisionTimingFn == nil {
 		return nil
 	}
-	return msc.PrecisionTimingFn(stat, delta)
+	return msc.PrecisionTimingFn(stat, delta, tags...)
 }
 
-func (msc *MockStatsdClient) Gauge(stat string, value int64) error {
+func (msc *MockStatsdClient) Gauge(stat string, value int64, tags ...string) error {
 	if msc.GaugeFn == nil {
 		return nil
 	}
-	return msc.GaugeFn(stat, value)
+	return msc.GaugeFn(stat, value, tags...)
 }
 
-func (msc *MockStatsdClient) GaugeDelta(stat string, value int64) error {
+func (msc *MockStatsdClient) GaugeDelta(stat string, value int64, tags ...string) error {
 	if msc.GaugeDeltaFn == nil {
 		return nil
 	}
-	return msc.GaugeDeltaFn(stat, value)
+	return msc.GaugeDeltaFn(stat, value, tags...)
 }
 
-func (msc *MockStatsdClient) Absolute(stat string, value int64) error {
+func (msc *MockStatsdClient) Absolute(stat string, value int64, tags ...string) error {
 	if msc.AbsoluteFn == nil {
 		return nil
 	}
-	return msc.AbsoluteFn(stat, value)
+	return msc.AbsoluteFn(stat, value, tags...)
 }
 
-func (msc *MockStatsdClient) Total(stat string, value int64) error {
+func (msc *MockStatsdClient) Total(stat string, value int64, tags ...string) error {
 	if msc.TotalFn == nil {
 		return nil
 	}
-	return msc.TotalFn(stat, value)
+	return msc.TotalFn(stat, value, tags...)
 }
 
-func (msc *MockStatsdClient) FGauge(stat string, value float64) error {
+func (msc *MockStatsdClient) FGauge(stat string, value float64, tags ...string) error {
 	if msc.FGaugeFn == nil {
 		return nil
 	}
-	return msc.FGaugeFn(stat, value)
+	return msc.FGaugeFn(stat, value, tags...)
 }
 
-func (msc *MockStatsdClient) FGaugeDelta(stat string, value float64) error {
+func (msc *MockStatsdClient) FGaugeDelta(stat string, value float64, tags ...string) error {
 	if msc.FGaugeDeltaFn == nil {
 		return nil
 	}
-	return msc.FGaugeDeltaFn(stat, value)
+	return msc.FGaugeDeltaFn(stat, value, tags...)
 }
 
-func (msc *MockStatsdClient) FAbsolute(stat string, value float64) error {
+func (msc *MockStatsdClient) FAbsolute(stat string, value float64, tags ...string) error {
 	if msc.FAbsoluteFn == nil {
 		return nil
 	}
-	return msc.FAbsoluteFn(stat, value)
+	return msc.FAbsoluteFn(stat, value, tags...)
+}
+
+func (msc *MockStatsdClient) Set(stat string, value string, tags ...string) error {
+	if msc.SetFn == nil {
+		return nil
+	}
+	return msc.SetFn(stat, value, tags...)
+}
+
+func (msc *MockStatsdClient) Histogram(stat string, value float64, tags ...string) error {
+	if msc.HistogramFn == nil {
+		return nil
+	}
+	return msc.HistogramFn(stat, value, tags...)
+}
+
+func (msc *MockStatsdClient) Distribution(stat string, value float64, tags ...string) error {
+	if msc.DistributionFn == nil {
+		return nil
+	}
+	return msc.DistributionFn(stat, value, tags...)
 }
 
 func (msc *MockStatsdClient) SendEvents(events map[string]event.Event) error {
@@ -169,16 +195,25 @@ func (msc *MockStatsdClient) SendEvents(events map[string]event.Event) error {
 type Int64Event struct {
 	MetricName string
 	EventValue int64
+	Tags       []string
 }
 
 type Float64Event struct {
 	MetricName string
 	EventValue float64
+	Tags       []string
 }
 
 type DurationEvent struct {
 	MetricName string
 	EventValue time.Duration
+	Tags       []string
+}
+
+type StringEvent struct {
+	MetricName string
+	EventValue string
+	Tags       []string
 }
 
 // UnvaluedEvents are useful for recording things like calls to Close() or CreateSocket()
@@ -223,8 +258,8 @@ func (msc *MockStatsdClient) RecordCloseEventsTo(closeEvents *[]UnvaluedEvent) *
 
 func (msc *MockStatsdClient) RecordIncrEventsTo(incrEvents *[]Int64Event) *MockStatsdClient {
 	eventLock := &sync.Mutex{}
-	msc.IncrFn = func(metricName string, eventValue int64) error {
-		recordInt64Event(eventLock, incrEvents, metricName, eventValue)
+	msc.IncrFn = func(metricName string, eventValue int64, tags ...string) error {
+		recordInt64Event(eventLock, incrEvents, metricName, eventValue, tags)
 		return nil
 	}
 	return msc
@@ -232,8 +267,8 @@ func (msc *MockStatsdClient) RecordIncrEventsTo(incrEvents *[]Int64Event) *MockS
 
 func (msc *MockStatsdClient) RecordDecrEventsTo(decrEvents *[]Int64Event) *MockStatsdClient {
 	eventLock := &sync.Mutex{}
-	msc.DecrFn = func(metricName string, eventValue int64) error {
-		recordInt64Event(eventLock, decrEvents, metricName, eventValue)
+	msc.DecrFn = func(metricName string, eventValue int64, tags ...string) error {
+		recordInt64Event(eventLock, decrEvents, metricName, eventValue, tags)
 		return nil
 	}
 	return msc
@@ -241,8 +276,8 @@ func (msc *MockStatsdClient) RecordDecrEventsTo(decrEvents *[]Int64Event) *MockS
 
 func (msc *MockStatsdClient) RecordTimingEventsTo(timingEvents *[]Int64Event) *MockStatsdClient {
 	eventLock := &sync.Mutex{}
-	msc.TimingFn = func(metricName string, eventValue int64) error {
-		recordInt64Event(eventLock, timingEvents, metricName, eventValue)
+	msc.TimingFn = func(metricName string, eventValue int64, tags ...string) error {
+		recordInt64Event(eventLock, timingEvents, metricName, eventValue, tags)
 		return nil
 	}
 	return msc
@@ -250,8 +285,8 @@ func (msc *MockStatsdClient) RecordTimingEventsTo(timingEvents *[]Int64Event) *M
 
 func (msc *MockStatsdClient) RecordPrecisionTimingEventsTo(timingEvents *[]DurationEvent) *MockStatsdClient {
 	eventLock := &sync.Mutex{}
-	msc.PrecisionTimingFn = func(metricName string, eventValue time.Duration) error {
-		recordDurationEvent(eventLock, timingEvents, metricName, eventValue)
+	msc.PrecisionTimingFn = func(metricName string, eventValue time.Duration, tags ...string) error {
+		recordDurationEvent(eventLock, timingEvents, metricName, eventValue, tags)
 		return nil
 	}
 	return msc
@@ -259,8 +294,8 @@ func (msc *MockStatsdClient) RecordPrecisionTimingEventsTo(timingEvents *[]Durat
 
 func (msc *MockStatsdClient) RecordGaugeEventsTo(gaugeEvents *[]Int64Event) *MockStatsdClient {
 	eventLock := &sync.Mutex{}
-	msc.GaugeFn = func(metricName string, eventValue int64) error {
-		recordInt64Event(eventLock, gaugeEvents, metricName, eventValue)
+	msc.GaugeFn = func(metricName string, eventValue int64, tags ...string) error {
+		recordInt64Event(eventLock, gaugeEvents, metricName, eventValue, tags)
 		return nil
 	}
 	return msc
@@ -268,8 +303,8 @@ func (msc *MockStatsdClient) RecordGaugeEventsTo(gaugeEvents *[]Int64Event) *Moc
 
 func (msc *MockStatsdClient) RecordGaugeDeltaEventsTo(gaugeDeltaEvents *[]Int64Event) *MockStatsdClient {
 	eventLock := &sync.Mutex{}
-	msc.GaugeDeltaFn = func(metricName string, eventValue int64) error {
-		recordInt64Event(eventLock, gaugeDeltaEvents, metricName, eventValue)
+	msc.GaugeDeltaFn = func(metricName string, eventValue int64, tags ...string) error {
+		recordInt64Event(eventLock, gaugeDeltaEvents, metricName, eventValue, tags)
 		return nil
 	}
 	return msc
@@ -277,8 +312,8 @@ func (msc *MockStatsdClient) RecordGaugeDeltaEventsTo(gaugeDeltaEvents *[]Int64E
 
 func (msc *MockStatsdClient) RecordAbsoluteEventsTo(absoluteEvents *[]Int64Event) *MockStatsdClient {
 	eventLock := &sync.Mutex{}
-	msc.AbsoluteFn = func(metricName string, eventValue int64) error {
-		recordInt64Event(eventLock, absoluteEvents, metricName, eventValue)
+	msc.AbsoluteFn = func(metricName string, eventValue int64, tags ...string) error {
+		recordInt64Event(eventLock, absoluteEvents, metricName, eventValue, tags)
 		return nil
 	}
 	return msc
@@ -286,8 +321,8 @@ func (msc *MockStatsdClient) RecordAbsoluteEventsTo(absoluteEvents *[]Int64Event
 
 func (msc *MockStatsdClient) RecordTotalEventsTo(totalEvents *[]Int64Event) *MockStatsdClient {
 	eventLock := &sync.Mutex{}
-	msc.TotalFn = func(metricName string, eventValue int64) error {
-		recordInt64Event(eventLock, totalEvents, metricName, eventValue)
+	msc.TotalFn = func(metricName string, eventValue int64, tags ...string) error {
+		recordInt64Event(eventLock, totalEvents, metricName, eventValue, tags)
 		return nil
 	}
 	return msc
@@ -295,8 +330,8 @@ func (msc *MockStatsdClient) RecordTotalEventsTo(totalEvents *[]Int64Event) *Moc
 
 func (msc *MockStatsdClient) RecordFGaugeEventsTo(fgaugeEvents *[]Float64Event) *MockStatsdClient {
 	eventLock := &sync.Mutex{}
-	msc.FGaugeFn = func(metricName string, eventValue float64) error {
-		recordFloat64Event(eventLock, fgaugeEvents, metricName, eventValue)
+	msc.FGaugeFn = func(metricName string, eventValue float64, tags ...string) error {
+		recordFloat64Event(eventLock, fgaugeEvents, metricName, eventValue, tags)
 		return nil
 	}
 	return msc
@@ -304,8 +339,8 @@ func (msc *MockStatsdClient) RecordFGaugeEventsTo(fgaugeEvents *[]Float64Event)
 
 func (msc *MockStatsdClient) RecordFGaugeDeltaEventsTo(fgaugeDeltaEvents *[]Float64Event) *MockStatsdClient {
 	eventLock := &sync.Mutex{}
-	msc.FGaugeDeltaFn = func(metricName string, eventValue float64) error {
-		recordFloat64Event(eventLock, fgaugeDeltaEvents, metricName, eventValue)
+	msc.FGaugeDeltaFn = func(metricName string, eventValue float64, tags ...string) error {
+		recordFloat64Event(eventLock, fgaugeDeltaEvents, metricName, eventValue, tags)
 		return nil
 	}
 	return msc
@@ -313,37 +348,78 @@ func (msc *MockStatsdClient) RecordFGaugeDeltaEventsTo(fgaugeDeltaEvents *[]Floa
 
 func (msc *MockStatsdClient) RecordFAbsoluteEventsTo(fabsoluteEvents *[]Float64Event) *MockStatsdClient {
 	eventLock := &sync.Mutex{}
-	msc.FAbsoluteFn = func(metricName string, eventValue float64) error {
-		recordFloat64Event(eventLock, fabsoluteEvents, metricName, eventValue)
+	msc.FAbsoluteFn = func(metricName string, eventValue float64, tags ...string) error {
+		recordFloat64Event(eventLock, fabsoluteEvents, metricName, eventValue, tags)
+		return nil
+	}
+	return msc
+}
+
+func (msc *MockStatsdClient) RecordSetEventsTo(setEvents *[]StringEvent) *MockStatsdClient {
+	eventLock := &sync.Mutex{}
+	msc.SetFn = func(metricName string, eventValue string, tags ...string) error {
+		recordStringEvent(eventLock, setEvents, metricName, eventValue, tags)
 		return nil
 	}
 	return msc
 }
 
-func recordDurationEvent(eventLock sync.Locker, events *[]DurationEvent, metricName string, eventValue time.Duration) {
+func (msc *MockStatsdClient) RecordHistogramEventsTo(histogramEvents *[]Float64Event) *MockStatsdClient {
+	eventLock := &sync.Mutex{}
+	msc.HistogramFn = func(metricName string, eventValue float64, tags ...string) error {
+		recordFloat64Event(eventLock, histogramEvents, metricName, eventValue, tags)
+		return nil
+	}
+	return msc
+}
+
+func (msc *MockStatsdClient) RecordDistributionEventsTo(distributionEvents *[]Float64Event) *MockStatsdClient {
+	eventLock := &sync.Mutex{}
+	msc.DistributionFn = func(metricName string, eventValue float64, tags ...string) error {
+		recordFloat64Event(eventLock, distributionEvents, metricName, eventValue, tags)
+		return nil
+	}
+	return msc
+}
+
+func recordDurationEvent(eventLock sync.Locker, events *[]DurationEvent, metricName string, eventValue time.Duration, tags []string) {
 	newEvent := DurationEvent{
 		MetricName: metricName,
 		EventValue: eventValue,
+		Tags:       tags,
 	}
 	eventLock.Lock()
 	defer eventLock.Unlock()
 	*events = append(*events, newEvent)
 }
 
-func recordFloat64Event(eventLock sync.Locker, events *[]Float64Event, metricName string, eventValue float64) {
+func recordFloat64Event(eventLock sync.Locker, events *[]Float64Event, metricName string, eventValue float64, tags []string) {
 	newEvent := Float64Event{
 		MetricName: metricName,
 		EventValue: eventValue,
+		Tags:       tags,
 	}
 	eventLock.Lock()
 	defer eventLock.Unlock()
 	*events = append(*events, newEvent)
 }
 
-func recordInt64Event(eventLock sync.Locker, events *[]Int64Event, metricName string, eventValue int64) {
+func recordInt64Event(eventLock sync.Locker, events *[]Int64Event, metricName string, eventValue int64, tags []string) {
 	newEvent := Int64Event{
 		MetricName: metricName,
 		EventValue: eventValue,
+		Tags:       tags,
+	}
+	eventLock.Lock()
+	defer eventLock.Unlock()
+	*events = append(*events, newEvent)
+}
+
+func recordStringEvent(eventLock sync.Locker, events *[]StringEvent, metricName string, eventValue string, tags []string) {
+	newEvent := StringEvent{
+		MetricName: metricName,
+		EventValue: eventValue,
+		Tags:       tags,
 	}
 	eventLock.Lock()
 	defer eventLock.Unlock()
@@ -67,6 +67,20 @@ func TestNoopBehavior(t *testing.T) {
 		t.Fail()
 	}
 
+	err = mockClient.Set("set", "user1")
+	if err != nil {
+		t.Fail()
+	}
+
+	err = mockClient.Histogram("histogram", 13.0)
+	if err != nil {
+		t.Fail()
+	}
+	err = mockClient.Distribution("distribution", 14.0)
+	if err != nil {
+		t.Fail()
+	}
+
 	err = mockClient.SendEvents(make(map[string]event.Event))
 	if err != nil {
 		t.Fail()
@@ -83,7 +97,7 @@ func TestMockStatsdClient_RecordCreateSocketEventsTo(t *testing.T) {
 	}
 	expectedEvents := []UnvaluedEvent{UnvaluedEvent{}}
 	if !reflect.DeepEqual(createSocketEvents, expectedEvents) {
-		t.Logf("Expected %s, saw %s", expectedEvents, createSocketEvents)
+		t.Logf("Expected %v, saw %v", expectedEvents, createSocketEvents)
 		t.Fail()
 	}
 }
@@ -98,7 +112,7 @@ func TestMockStatsdClient_RecordCreateTCPSocketEventsTo(t *testing.T) {
 	}
 	expectedEvents := []UnvaluedEvent{UnvaluedEvent{}}
 	if !reflect.DeepEqual(createTCPSocketEvents, expectedEvents) {
-		t.Logf("Expected %s, saw %s", expectedEvents, createTCPSocketEvents)
+		t.Logf("Expected %v, saw %v", expectedEvents, createTCPSocketEvents)
 		t.Fail()
 	}
 }
@@ -113,7 +127,7 @@ func TestMockStatsdClient_RecordCloseEventsTo(t *testing.T) {
 	}
 	expectedEvents := []UnvaluedEvent{UnvaluedEvent{}}
 	if !reflect.DeepEqual(closeEvents, expectedEvents) {
-		t.Logf("Expected %s, saw %s", expectedEvents, closeEvents)
+		t.Logf("Expected %v, saw %v", expectedEvents, closeEvents)
 		t.Fail()
 	}
 }
@@ -128,7 +142,7 @@ func TestMockStatsdClient_RecordIncrEventsTo(t *testing.T) {
 	}
 	expectedEvents := []Int64Event{Int64Event{MetricName: "incr", EventValue: 1}}
 	if !reflect.DeepEqual(incrEvents, expectedEvents) {
-		t.Logf("Expected %s, saw %s", expectedEvents, incrEvents)
+		t.Logf("Expected %v, saw %v", expectedEvents, incrEvents)
 		t.Fail()
 	}
 }
@@ -143,7 +157,7 @@ func TestMockStatsdClient_Decr(t *testing.T) {
 	}
 	expectedEvents := []Int64Event{Int64Event{MetricName: "decr", EventValue: 1}}
 	if !reflect.DeepEqual(decrEvents, expectedEvents) {
-		t.Logf("Expected %s, saw %s", expectedEvents, decrEvents)
+		t.Logf("Expected %v, saw %v", expectedEvents, decrEvents)
 		t.Fail()
 	}
 }
@@ -158,7 +172,7 @@ func TestMockStatsdClient_Timing(t *testing.T) {
 	}
 	expectedEvents := []Int64Event{Int64Event{MetricName: "timing", EventValue: 1}}
 	if !reflect.DeepEqual(timingEvents, expectedEvents) {
-		t.Logf("Expected %s, saw %s", expectedEvents, timingEvents)
+		t.Logf("Expected %v, saw %v", expectedEvents, timingEvents)
 		t.Fail()
 	}
 }
@@ -173,7 +187,7 @@ func TestMockStatsdClient_RecordPrecisionTimingEventsTo(t *testing.T) {
 	}
 	expectedEvents := []DurationEvent{DurationEvent{MetricName: "precisionTiming", EventValue: 1}}
 	if !reflect.DeepEqual(durationEvents, expectedEvents) {
-		t.Logf("Expected %s, saw %s", expectedEvents, durationEvents)
+		t.Logf("Expected %v, saw %v", expectedEvents, durationEvents)
 		t.Fail()
 	}
 }
@@ -188,7 +202,7 @@ func TestMockStatsdClient_RecordGaugeEventsTo(t *testing.T) {
 	}
 	expectedEvents := []Int64Event{Int64Event{MetricName: "gauge", EventValue: 1}}
 	if !reflect.DeepEqual(gaugeEvents, expectedEvents) {
-		t.Logf("Expected %s, saw %s", expectedEvents, gaugeEvents)
+		t.Logf("Expected %v, saw %v", expectedEvents, gaugeEvents)
 		t.Fail()
 	}
 }
@@ -203,7 +217,7 @@ func TestMockStatsdClient_RecordFGaugeDeltaEventsTo(t *testing.T) {
 	}
 	expectedEvents := []Int64Event{Int64Event{MetricName: "gaugeDelta", EventValue: 1}}
 	if !reflect.DeepEqual(gaugeDeltaEvents, expectedEvents) {
-		t.Logf("Expected %s, saw %s", expectedEvents, gaugeDeltaEvents)
+		t.Logf("Expected %v, saw %v", expectedEvents, gaugeDeltaEvents)
 		t.Fail()
 	}
 }
@@ -218,7 +232,7 @@ func TestMockStatsdClient_RecordAbsoluteEventsTo(t *testing.T) {
 	}
 	expectedEvents := []Int64Event{Int64Event{MetricName: "absolute", EventValue: 1}}
 	if !reflect.DeepEqual(absoluteEvents, expectedEvents) {
-		t.Logf("Expected %s, saw %s", expectedEvents, absoluteEvents)
+		t.Logf("Expected %v, saw %v", expectedEvents, absoluteEvents)
 		t.Fail()
 	}
 }
@@ -233,7 +247,7 @@ func TestMockStatsdClient_RecordTotalEventsTo(t *testing.T) {
 	}
 	expectedEvents := []Int64Event{Int64Event{MetricName: "total", EventValue: 1}}
 	if !reflect.DeepEqual(totalEvents, expectedEvents) {
-		t.Logf("Expected %s, saw %s", expectedEvents, totalEvents)
+		t.Logf("Expected %v, saw %v", expectedEvents, totalEvents)
 		t.Fail()
 	}
 }
@@ -248,7 +262,7 @@ func TestMockStatsdClient_RecordFGaugeEventsTo(t *testing.T) {
 	}
 	expectedEvents := []Float64Event{Float64Event{MetricName: "fgauge", EventValue: 1}}
 	if !reflect.DeepEqual(fgaugeEvents, expectedEvents) {
-		t.Logf("Expected %s, saw %s", expectedEvents, fgaugeEvents)
+		t.Logf("Expected %v, saw %v", expectedEvents, fgaugeEvents)
 		t.Fail()
 	}
 }
@@ -263,7 +277,7 @@ func TestMockStatsdClient_RecordFGaugeDeltaEventsTo2(t *testing.T) {
 	}
 	expectedEvents := []Float64Event{Float64Event{MetricName: "fgaugeDelta", EventValue: 1}}
 	if !reflect.DeepEqual(fgaugeDeltaEvents, expectedEvents) {
-		t.Logf("Expected %s, saw %s", expectedEvents, fgaugeDeltaEvents)
+		t.Logf("Expected %v, saw %v", expectedEvents, fgaugeDeltaEvents)
 		t.Fail()
 	}
 }
@@ -278,7 +292,52 @@ func TestMockStatsdClient_RecordFAbsoluteEventsTo(t *testing.T) {
 	}
 	expectedEvents := []Float64Event{Float64Event{MetricName: "fabsolute", EventValue: 1}}
 	if !reflect.DeepEqual(fabsoluteEvents, expectedEvents) {
-		t.Logf("Expected %s, saw %s", expectedEvents, fabsoluteEvents)
+		t.Logf("Expected %v, saw %v", expectedEvents, fabsoluteEvents)
+		t.Fail()
+	}
+}
+
+func TestMockStatsdClient_RecordSetEventsTo(t *testing.T) {
+	var setEvents []StringEvent
+	mockClient := (&MockStatsdClient{}).RecordSetEventsTo(&setEvents)
+	err := mockClient.Set("set", "user1")
+	if err != nil {
+		t.Logf("Got non-nil err from mock Set")
+		t.Fail()
+	}
+	expectedEvents := []StringEvent{StringEvent{MetricName: "set", EventValue: "user1"}}
+	if !reflect.DeepEqual(setEvents, expectedEvents) {
+		t.Logf("Expected %v, saw %v", expectedEvents, setEvents)
+		t.Fail()
+	}
+}
+
+func TestMockStatsdClient_RecordHistogramEventsTo(t *testing.T) {
+	var histogramEvents []Float64Event
+	mockClient := (&MockStatsdClient{}).RecordHistogramEventsTo(&histogramEvents)
+	err := mockClient.Histogram("histogram", 1.5)
+	if err != nil {
+		t.Logf("Got non-nil err from mock Histogram")
+		t.Fail()
+	}
+	expectedEvents := []Float64Event{Float64Event{MetricName: "histogram", EventValue: 1.5}}
+	if !reflect.DeepEqual(histogramEvents, expectedEvents) {
+		t.Logf("Expected %v, saw %v", expectedEvents, histogramEvents)
+		t.Fail()
+	}
+}
+
+func TestMockStatsdClient_RecordDistributionEventsTo(t *testing.T) {
+	var distributionEvents []Float64Event
+	mockClient := (&MockStatsdClient{}).RecordDistributionEventsTo(&distributionEvents)
+	err := mockClient.Distribution("distribution", 2.5)
+	if err != nil {
+		t.Logf("Got non-nil err from mock Distribution")
+		t.Fail()
+	}
+	expectedEvents := []Float64Event{Float64Event{MetricName: "distribution", EventValue: 2.5}}
+	if !reflect.DeepEqual(distributionEvents, expectedEvents) {
+		t.Logf("Expected %v, saw %v", expectedEvents, distributionEvents)
 		t.Fail()
 	}
 }
@@ -339,3 +398,18 @@ func TestMockStatsdClient_RecordFAbsoluteEventsTo(t *testing.T) {
 //		t.Fail()
 //	}
 //}
+
+func TestMockStatsdClient_RecordTags(t *testing.T) {
+	var incrEvents []Int64Event
+	mockClient := (&MockStatsdClient{}).RecordIncrEventsTo(&incrEvents)
+	err := mockClient.Incr("incr", 1, "env:prod", "route:/api")
+	if err != nil {
+		t.Logf("Got non-nil err from mock Incr")
+		t.Fail()
+	}
+	expectedEvents := []Int64Event{Int64Event{MetricName: "incr", EventValue: 1, Tags: []string{"env:prod", "route:/api"}}}
+	if !reflect.DeepEqual(incrEvents, expectedEvents) {
+		t.Logf("Expected %v, saw %v", expectedEvents, incrEvents)
+		t.Fail()
+	}
+}
@@ -0,0 +1,176 @@
+package statsd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quipo/statsd/event"
+)
+
+// BackendError is the error returned by one of the backends of a MultiClient
+type BackendError struct {
+	Backend int // index of the backend, in the order given to NewMultiClient
+	Err     error
+}
+
+// Error returns the description of the error and the index of the backend
+func (e *BackendError) Error() string {
+	return fmt.Sprintf("statsd backend %d: %s", e.Backend, e.Err)
+}
+
+// Unwrap returns the error of the backend
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError is the list of errors of the backends of a MultiClient which failed
+type MultiError []*BackendError
+
+// Error returns the description of all the errors
+func (e MultiError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// MultiClient forwards every call to several Statsd backends, e.g. to send the same metrics
+// to two servers during a migration, or to a server and a StdoutClient.
+// A backend failing (or panicking) doesn't prevent the others from receiving the metrics:
+// the errors are returned together as a MultiError.
+// The same event objects are passed to the SendEvents of every backend: to buffer the metrics,
+// wrap the MultiClient in a StatsdBuffer rather than using StatsdBuffers as backends.
+type MultiClient struct {
+	backends []Statsd
+}
+
+// NewMultiClient - Factory
+func NewMultiClient(backends ...Statsd) *MultiClient {
+	return &MultiClient{backends: backends}
+}
+
+// Backends returns the backends the calls are forwarded to
+func (m *MultiClient) Backends() []Statsd {
+	return m.backends
+}
+
+// each calls fn for every backend, collecting the errors
+func (m *MultiClient) each(fn func(Statsd) error) error {
+	var errs MultiError
+	for i, backend := range m.backends {
+		if err := callBackend(backend, fn); nil != err {
+			errs = append(errs, &BackendError{Backend: i, Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// callBackend calls fn, turning a panic into an error
+func callBackend(backend Statsd, fn func(Statsd) error) (err error) {
+	defer func() {
+		if r := recover(); nil != r {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(backend)
+}
+
+// CreateSocket creates a UDP connection in every backend
+func (m *MultiClient) CreateSocket() error {
+	return m.each(func(s Statsd) error { return s.CreateSocket() })
+}
+
+// CreateTCPSocket creates a TCP connection in every backend
+func (m *MultiClient) CreateTCPSocket() error {
+	return m.each(func(s Statsd) error { return s.CreateTCPSocket() })
+}
+
+// Close all the backends
+func (m *MultiClient) Close() error {
+	return m.each(func(s Statsd) error { return s.Close() })
+}
+
+// Incr - Increment a counter metric. Often used to note a particular event
+func (m *MultiClient) Incr(stat string, count int64, tags ...string) error {
+	return m.each(func(s Statsd) error { return s.Incr(stat, count, tags...) })
+}
+
+// Decr - Decrement a counter metric. Often used to note a particular event
+func (m *MultiClient) Decr(stat string, count int64, tags ...string) error {
+	return m.each(func(s Statsd) error { return s.Decr(stat, count, tags...) })
+}
+
+// Timing - Track a duration event
+// the time delta must be given in milliseconds
+func (m *MultiClient) Timing(stat string, delta int64, tags ...string) error {
+	return m.each(func(s Statsd) error { return s.Timing(stat, delta, tags...) })
+}
+
+// PrecisionTiming - Track a duration event
+// the time delta has to be a duration
+func (m *MultiClient) PrecisionTiming(stat string, delta time.Duration, tags ...string) error {
+	return m.each(func(s Statsd) error { return s.PrecisionTiming(stat, delta, tags...) })
+}
+
+// Gauge - Gauges are a constant data type. They are not subject to averaging,
+// and they don’t change unless you change them. That is, once you set a gauge value,
+// it will be a flat line on the graph until you change it again
+func (m *MultiClient) Gauge(stat string, value int64, tags ...string) error {
+	return m.each(func(s Statsd) error { return s.Gauge(stat, value, tags...) })
+}
+
+// GaugeDelta records a delta from the previous value (as int64)
+func (m *MultiClient) GaugeDelta(stat string, value int64, tags ...string) error {
+	return m.each(func(s Statsd) error { return s.GaugeDelta(stat, value, tags...) })
+}
+
+// FGauge is a Gauge working with float64 values
+func (m *MultiClient) FGauge(stat string, value float64, tags ...string) error {
+	return m.each(func(s Statsd) error { return s.FGauge(stat, value, tags...) })
+}
+
+// FGaugeDelta records a delta from the previous value (as float64)
+func (m *MultiClient) FGaugeDelta(stat string, value float64, tags ...string) error {
+	return m.each(func(s Statsd) error { return s.FGaugeDelta(stat, value, tags...) })
+}
+
+// Absolute - Send absolute-valued metric (not averaged/aggregated)
+func (m *MultiClient) Absolute(stat string, value int64, tags ...string) error {
+	return m.each(func(s Statsd) error { return s.Absolute(stat, value, tags...) })
+}
+
+// FAbsolute - Send absolute-valued floating point metric (not averaged/aggregated)
+func (m *MultiClient) FAbsolute(stat string, value float64, tags ...string) error {
+	return m.each(func(s Statsd) error { return s.FAbsolute(stat, value, tags...) })
+}
+
+// Total - Send a metric that is continously increasing, e.g. read operations since boot
+func (m *MultiClient) Total(stat string, value int64, tags ...string) error {
+	return m.each(func(s Statsd) error { return s.Total(stat, value, tags...) })
+}
+
+// Set - Send a value to be counted as unique per flush interval (e.g. unique users)
+func (m *MultiClient) Set(stat string, value string, tags ...string) error {
+	return m.each(func(s Statsd) error { return s.Set(stat, value, tags...) })
+}
+
+// Histogram - Send a value to be aggregated into a statistical distribution by the server (per host)
+func (m *MultiClient) Histogram(stat string, value float64, tags ...string) error {
+	return m.each(func(s Statsd) error { return s.Histogram(stat, value, tags...) })
+}
+
+// Distribution - Send a value to be aggregated into a statistical distribution
+// by the server (globally, across all hosts)
+func (m *MultiClient) Distribution(stat string, value float64, tags ...string) error {
+	return m.each(func(s Statsd) error { return s.Distribution(stat, value, tags...) })
+}
+
+// SendEvents - Sends stats from all the event objects to every backend
+func (m *MultiClient) SendEvents(events map[string]event.Event) error {
+	return m.each(func(s Statsd) error { return s.SendEvents(events) })
+}
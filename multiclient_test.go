@@ -0,0 +1,73 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/quipo/statsd/event"
+	"github.com/quipo/statsd/statsdtest"
+)
+
+var _ Statsd = (*MultiClient)(nil)
+
+// panickingClient panics on every metric
+type panickingClient struct {
+	NoopClient
+}
+
+func (c panickingClient) Incr(stat string, count int64, tags ...string) error {
+	panic("boom")
+}
+
+func TestMultiClient(t *testing.T) {
+	srv1, err := statsdtest.NewServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer srv1.Close()
+	srv2, err := statsdtest.NewServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer srv2.Close()
+
+	client1 := NewStatsdClient(srv1.Addr, "test.")
+	client2 := NewStatsdClient(srv2.Addr, "test.")
+	notConnected := NewStatsdClient(srv2.Addr, "test.")
+	if err = client1.CreateSocket(); nil != err {
+		t.Fatal(err)
+	}
+	if err = client2.CreateSocket(); nil != err {
+		t.Fatal(err)
+	}
+
+	multi := NewMultiClient(client1, notConnected, panickingClient{}, client2)
+	defer multi.Close()
+
+	err = multi.Incr("req", 1, "env:prod")
+	errs, ok := err.(MultiError)
+	if !ok {
+		t.Fatalf("expected a MultiError, got %T %v", err, err)
+	}
+	if 2 != len(errs) || 1 != errs[0].Backend || 2 != errs[1].Backend {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	if errNotConnected != errs[0].Err {
+		t.Errorf("unexpected error: %v", errs[0].Err)
+	}
+
+	// the other backends are not affected
+	if err = multi.SendEvents(map[string]event.Event{"g": &event.Gauge{Name: "g", Value: 5}}); nil == err {
+		t.Error("expected an error from the backend not connected")
+	}
+	for _, srv := range []*statsdtest.Server{srv1, srv2} {
+		if err = srv.WaitFor(2); nil != err {
+			t.Fatal(err)
+		}
+		srv.AssertCounter(t, "test.req", 1, "env:prod")
+		srv.AssertGauge(t, "test.g", 5)
+	}
+
+	if err = NewMultiClient(client1, client2).Gauge("g", 6); nil != err {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
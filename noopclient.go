@@ -27,57 +27,72 @@ func (s NoopClient) Close() error {
 }
 
 // Incr does nothing
-func (s NoopClient) Incr(stat string, count int64) error {
+func (s NoopClient) Incr(stat string, count int64, tags ...string) error {
 	return nil
 }
 
 // Decr does nothing
-func (s NoopClient) Decr(stat string, count int64) error {
+func (s NoopClient) Decr(stat string, count int64, tags ...string) error {
 	return nil
 }
 
 // Timing does nothing
-func (s NoopClient) Timing(stat string, count int64) error {
+func (s NoopClient) Timing(stat string, count int64, tags ...string) error {
 	return nil
 }
 
 // PrecisionTiming does nothing
-func (s NoopClient) PrecisionTiming(stat string, delta time.Duration) error {
+func (s NoopClient) PrecisionTiming(stat string, delta time.Duration, tags ...string) error {
 	return nil
 }
 
 // Gauge does nothing
-func (s NoopClient) Gauge(stat string, value int64) error {
+func (s NoopClient) Gauge(stat string, value int64, tags ...string) error {
 	return nil
 }
 
 // GaugeDelta does nothing
-func (s NoopClient) GaugeDelta(stat string, value int64) error {
+func (s NoopClient) GaugeDelta(stat string, value int64, tags ...string) error {
 	return nil
 }
 
 // Absolute does nothing
-func (s NoopClient) Absolute(stat string, value int64) error {
+func (s NoopClient) Absolute(stat string, value int64, tags ...string) error {
 	return nil
 }
 
 // Total does nothing
-func (s NoopClient) Total(stat string, value int64) error {
+func (s NoopClient) Total(stat string, value int64, tags ...string) error {
 	return nil
 }
 
 // FGauge does nothing
-func (s NoopClient) FGauge(stat string, value float64) error {
+func (s NoopClient) FGauge(stat string, value float64, tags ...string) error {
 	return nil
 }
 
 // FGaugeDelta does nothing
-func (s NoopClient) FGaugeDelta(stat string, value float64) error {
+func (s NoopClient) FGaugeDelta(stat string, value float64, tags ...string) error {
 	return nil
 }
 
 // FAbsolute does nothing
-func (s NoopClient) FAbsolute(stat string, value float64) error {
+func (s NoopClient) FAbsolute(stat string, value float64, tags ...string) error {
+	return nil
+}
+
+// Set does nothing
+func (s NoopClient) Set(stat string, value string, tags ...string) error {
+	return nil
+}
+
+// Histogram does nothing
+func (s NoopClient) Histogram(stat string, value float64, tags ...string) error {
+	return nil
+}
+
+// Distribution does nothing
+func (s NoopClient) Distribution(stat string, value float64, tags ...string) error {
 	return nil
 }
 
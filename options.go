@@ -0,0 +1,271 @@
+package statsd
+
+import (
+	"fmt"
+	"time"
+)
+
+// Option configures a client created with NewStatsdClientWithOptions
+// or NewStatsdBufferWithOptions. The options which don't apply to the
+// client being created are ignored.
+type Option func(*options)
+
+type options struct {
+	payloadSize     int
+	hostname        string
+	dialTimeout     time.Duration
+	transport       socketType
+	reconnect       *ReconnectPolicy
+	resolveInterval time.Duration
+	coalesce        time.Duration
+	channelCapacity int
+	overflow        OverflowPolicy
+	shards          int
+	logger          Logger
+	verbose         bool
+	percentiles     []float64
+	spool           *Spool
+	telemetry       string // namespace, if enabled
+	telemetryPeriod time.Duration
+	onError         ErrorHandler
+	maxKeys         int
+	prefixMaxKeys   map[string]int
+	limitPolicy     CardinalityPolicy
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		transport:       udpSocket,
+		channelCapacity: 100,
+		verbose:         true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithPayloadSize sets the maximum number of bytes SendEvents packs into a single packet
+// (StatsdClient, default UDPPayloadSize)
+func WithPayloadSize(size int) Option {
+	return func(o *options) {
+		o.payloadSize = size
+	}
+}
+
+// WithHostname sets the hostname replacing %HOST% in the prefix and in the metric names
+// (StatsdClient, default Hostname)
+func WithHostname(hostname string) Option {
+	return func(o *options) {
+		o.hostname = hostname
+	}
+}
+
+// WithDialTimeout sets the timeout for connecting to the StatsD server (StatsdClient, default 5s)
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.dialTimeout = timeout
+	}
+}
+
+// WithTransport sets the network to connect with: "udp" (default), "tcp", "unix" or "unixgram" (StatsdClient)
+func WithTransport(network string) Option {
+	return func(o *options) {
+		o.transport = socketType(network)
+	}
+}
+
+// WithReconnect sets the ReconnectPolicy of TCP and Unix stream connections (StatsdClient)
+func WithReconnect(policy *ReconnectPolicy) Option {
+	return func(o *options) {
+		o.reconnect = policy
+	}
+}
+
+// WithResolveInterval sets how often the hostname of a UDP address is resolved again (StatsdClient)
+func WithResolveInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.resolveInterval = interval
+	}
+}
+
+// WithCoalescing makes the metric functions queue their lines and send them together
+// in packets of up to the payload size, at least at every interval (StatsdClient)
+func WithCoalescing(interval time.Duration) Option {
+	return func(o *options) {
+		o.coalesce = interval
+	}
+}
+
+// WithChannelCapacity sets the number of events StatsdBuffer queues
+// before the metric functions block (StatsdBuffer, default 100)
+func WithChannelCapacity(capacity int) Option {
+	return func(o *options) {
+		o.channelCapacity = capacity
+	}
+}
+
+// WithOverflowPolicy sets what StatsdBuffer does with new events when its queue is full
+// (StatsdBuffer, default OverflowBlock)
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(o *options) {
+		o.overflow = policy
+	}
+}
+
+// WithShards makes StatsdBuffer aggregate the events directly in the goroutines sending them,
+// split into n shards by key (e.g. runtime.GOMAXPROCS(0)), instead of queueing them to a single
+// collector goroutine. The shards are merged at flush time. The overflow policy is not used,
+// as the metric functions never block (StatsdBuffer, default 0: not sharded)
+func WithShards(n int) Option {
+	return func(o *options) {
+		o.shards = n
+	}
+}
+
+// WithLogger sets the Logger (StatsdClient and StatsdBuffer)
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithVerbose sets whether StatsdBuffer logs when it's closed (StatsdBuffer, default true)
+func WithVerbose(verbose bool) Option {
+	return func(o *options) {
+		o.verbose = verbose
+	}
+}
+
+// WithPercentiles sets the percentiles to compute for Timing and PrecisionTiming events (StatsdBuffer)
+func WithPercentiles(percentiles ...float64) Option {
+	return func(o *options) {
+		o.percentiles = percentiles
+	}
+}
+
+// WithSpool sets the Spool persisting the events which can't be flushed (StatsdBuffer)
+func WithSpool(spool *Spool) Option {
+	return func(o *options) {
+		o.spool = spool
+	}
+}
+
+// WithTelemetry sends the metrics about the client itself under the namespace
+// (DefaultTelemetryNamespace if empty). A StatsdClient sends the packets and bytes written
+// and the write errors at every telemetry interval (see WithTelemetryInterval).
+// A StatsdBuffer sends the events received, dropped and rejected, the flush errors,
+// the size and duration of the last flush at each flush and, for a StatsdClient
+// not sending its own, the metrics of the StatsdClient (StatsdClient and StatsdBuffer)
+func WithTelemetry(namespace string) Option {
+	return func(o *options) {
+		if "" == namespace {
+			namespace = DefaultTelemetryNamespace
+		}
+		o.telemetry = namespace
+	}
+}
+
+// WithTelemetryInterval sets how often the metrics about the client itself are sent
+// with WithTelemetry (StatsdClient, default DefaultTelemetryInterval)
+func WithTelemetryInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.telemetryPeriod = interval
+	}
+}
+
+// WithErrorHandler sets the handler receiving the errors happening in the background,
+// instead of the logger (StatsdClient and StatsdBuffer)
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(o *options) {
+		o.onError = handler
+	}
+}
+
+// WithCardinalityLimit caps the number of distinct keys (name, type and tags) aggregated
+// per flush interval, applying the policy to the events of the new keys beyond the limit,
+// here and for the limits set with WithPrefixCardinalityLimit (StatsdBuffer, default 0: no limit)
+func WithCardinalityLimit(maxKeys int, policy CardinalityPolicy) Option {
+	return func(o *options) {
+		o.maxKeys = maxKeys
+		o.limitPolicy = policy
+	}
+}
+
+// WithPrefixCardinalityLimit caps the number of distinct keys per flush interval for
+// the metric names starting with the prefix (StatsdBuffer). The longest prefix applies.
+func WithPrefixCardinalityLimit(prefix string, maxKeys int) Option {
+	return func(o *options) {
+		if nil == o.prefixMaxKeys {
+			o.prefixMaxKeys = make(map[string]int)
+		}
+		o.prefixMaxKeys[prefix] = maxKeys
+	}
+}
+
+// NewStatsdClientWithOptions is a factory for a StatsdClient connected to the StatsD server
+// with the given transport (UDP by default), configured independently of the package globals.
+// With WithReconnect and a stream transport, a server which can't be reached yet is not an error:
+// the client keeps connecting in the background.
+func NewStatsdClientWithOptions(addr string, prefix string, opts ...Option) (*StatsdClient, error) {
+	o := newOptions(opts)
+	switch o.transport {
+	case udpSocket, tcpSocket, unixSocket, unixgramSocket:
+	default:
+		return nil, fmt.Errorf("statsd: unsupported transport %q", o.transport)
+	}
+	if o.payloadSize < 0 {
+		return nil, fmt.Errorf("statsd: invalid payload size %d", o.payloadSize)
+	}
+
+	c := NewStatsdClient(addr, "")
+	c.hostname = o.hostname
+	c.prefix = expandTemplate(prefix, c.host())
+	c.payloadSize = o.payloadSize
+	c.dialTimeout = o.dialTimeout
+	c.Reconnect = o.reconnect
+	c.ResolveInterval = o.resolveInterval
+	c.CoalesceInterval = o.coalesce
+	c.OnError = o.onError
+	if nil != o.logger {
+		c.Logger = o.logger
+	}
+	if "" != o.telemetry {
+		c.telemetry = &telemetry{namespace: o.telemetry}
+		c.telemetryInterval = o.telemetryPeriod
+	}
+	if err := c.dial(o.transport); nil != err {
+		if nil != c.Reconnect && o.transport.isStream() {
+			// the server is not up yet: keep reconnecting in the background
+			handleError(c.OnError, c.Logger, &Error{Kind: ErrorConnection, Err: err}, "Cannot connect to", addr, "reconnecting:", err)
+			return c, nil
+		}
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewStatsdBufferWithOptions is a factory for a StatsdBuffer
+// flushing the aggregated events to the given client at every interval
+func NewStatsdBufferWithOptions(interval time.Duration, client Statsd, opts ...Option) (*StatsdBuffer, error) {
+	o := newOptions(opts)
+	if o.channelCapacity < 0 {
+		return nil, fmt.Errorf("statsd: invalid channel capacity %d", o.channelCapacity)
+	}
+	if 0 == o.channelCapacity && OverflowDropOldest == o.overflow {
+		return nil, fmt.Errorf("statsd: the %s overflow policy needs a channel capacity", o.overflow)
+	}
+	if o.shards < 0 {
+		return nil, fmt.Errorf("statsd: invalid number of shards %d", o.shards)
+	}
+	if o.maxKeys < 0 {
+		return nil, fmt.Errorf("statsd: invalid cardinality limit %d", o.maxKeys)
+	}
+	for prefix, max := range o.prefixMaxKeys {
+		if max < 0 {
+			return nil, fmt.Errorf("statsd: invalid cardinality limit %d for %q", max, prefix)
+		}
+	}
+	return newStatsdBuffer(interval, client, o), nil
+}
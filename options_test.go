@@ -0,0 +1,132 @@
+package statsd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/quipo/statsd/statsdtest"
+)
+
+func TestClientWithOptions(t *testing.T) {
+	srv, err := statsdtest.NewTCPServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+	client, err := NewStatsdClientWithOptions(srv.Addr, "%HOST%.",
+		WithTransport("tcp"),
+		WithHostname("host1"),
+		WithPayloadSize(1024),
+		WithDialTimeout(time.Second),
+		WithLogger(logger),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// configured independently of the package globals and of other clients
+	other := NewStatsdClient(srv.Addr, "")
+	if 1024 != client.maxPayload() || UDPPayloadSize != other.maxPayload() {
+		t.Errorf("wrong payload size: %d, %d", client.maxPayload(), other.maxPayload())
+	}
+	if "host1" != client.host() || Hostname != other.host() {
+		t.Errorf("wrong hostname: %s, %s", client.host(), other.host())
+	}
+	if time.Second != client.timeout() || defaultDialTimeout != other.timeout() {
+		t.Errorf("wrong dial timeout: %s, %s", client.timeout(), other.timeout())
+	}
+	if logger != client.Logger {
+		t.Error("the logger was not set")
+	}
+
+	client.Incr("req.%HOST%", 1)
+	if err = srv.WaitFor(1); nil != err {
+		t.Fatal(err)
+	}
+	srv.AssertCounter(t, "host1.req.host1", 1)
+}
+
+func TestClientWithInvalidOptions(t *testing.T) {
+	if _, err := NewStatsdClientWithOptions("127.0.0.1:1201", "", WithTransport("http")); nil == err {
+		t.Error("expected an error for an unsupported transport")
+	}
+	if _, err := NewStatsdClientWithOptions("127.0.0.1:1201", "", WithPayloadSize(-1)); nil == err {
+		t.Error("expected an error for a negative payload size")
+	}
+}
+
+func TestBufferWithOptions(t *testing.T) {
+	client := NewStatsdClient("127.0.0.1:1201", "test.")
+	client.conn = &MockNetConn{}
+
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+	buffered, err := NewStatsdBufferWithOptions(time.Hour, client,
+		WithChannelCapacity(5),
+		WithLogger(logger),
+		WithVerbose(false),
+		WithPercentiles(50, 99),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer buffered.Close()
+
+	if 5 != cap(buffered.eventChannel) {
+		t.Errorf("wrong channel capacity: Expected: 5, Actual: %d", cap(buffered.eventChannel))
+	}
+	if logger != buffered.Logger || buffered.Verbose {
+		t.Error("the logger options were not set")
+	}
+	if expected := []float64{50, 99}; !reflect.DeepEqual(expected, buffered.Percentiles) {
+		t.Errorf("wrong percentiles: Expected: %v, Actual: %v", expected, buffered.Percentiles)
+	}
+
+	if _, err = NewStatsdBufferWithOptions(time.Hour, client, WithChannelCapacity(-1)); nil == err {
+		t.Error("expected an error for a negative channel capacity")
+	}
+}
+
+func TestClientWithOptionsReconnect(t *testing.T) {
+	// the server is not up yet
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	if _, err = NewStatsdClientWithOptions(addr, "", WithTransport("tcp")); nil == err {
+		t.Error("expected an error without a reconnect policy")
+	}
+
+	client, err := NewStatsdClientWithOptions(addr, "",
+		WithTransport("tcp"),
+		WithReconnect(&ReconnectPolicy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond}),
+		WithLogger(log.New(ioutil.Discard, "", 0)),
+	)
+	if nil != err {
+		t.Fatalf("unexpected error with a reconnect policy: %v", err)
+	}
+	defer client.Close()
+
+	if ln, err = net.Listen("tcp", addr); nil != err {
+		t.Skipf("cannot listen to %s again: %v", addr, err)
+	}
+	defer ln.Close()
+	for i := 0; i < 200 && StateConnected != client.State(); i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if StateConnected != client.State() {
+		t.Error("the client did not connect once the server was up")
+	}
+}
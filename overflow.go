@@ -0,0 +1,123 @@
+package statsd
+
+import (
+	"math"
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/quipo/statsd/event"
+)
+
+// OverflowPolicy is what StatsdBuffer does with a new event when its queue is full,
+// e.g. because the collector is busy flushing to a slow server
+type OverflowPolicy int
+
+// overflow policies
+const (
+	// OverflowBlock waits for the collector to make room in the queue (default)
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the new event
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest queued event to make room for the new one
+	// (or the new one, without a queue)
+	OverflowDropOldest
+	// OverflowSample starts sampling the events when the queue is more than half full,
+	// with a probability decreasing with the room left, and drops them when it's full.
+	// The values of the sampled counters are scaled up, so their totals stay unbiased.
+	OverflowSample
+)
+
+// String returns the name of the policy
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropNewest:
+		return "drop-newest"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowSample:
+		return "sample"
+	}
+	return "unknown"
+}
+
+// Dropped returns the number of events dropped because the queue was full
+func (sb *StatsdBuffer) Dropped() uint64 {
+	return atomic.LoadUint64(&sb.dropped)
+}
+
+// enqueue sends an event to the collector, applying the overflow policy,
+// or aggregates it into its shard (which never blocks)
+func (sb *StatsdBuffer) enqueue(e event.Event) {
+	if nil != sb.shards {
+		// counted by the shard, not to contend on a single counter
+		if err := sb.shards.aggregate(e); nil != err {
+			sb.typeConflict(e, err)
+		}
+		return
+	}
+	sb.received()
+	switch sb.Overflow {
+	case OverflowDropNewest:
+		select {
+		case sb.eventChannel <- e:
+		default:
+			atomic.AddUint64(&sb.dropped, 1)
+		}
+	case OverflowDropOldest:
+		if 0 == cap(sb.eventChannel) {
+			// no queued event to drop: drop the new one
+			select {
+			case sb.eventChannel <- e:
+			default:
+				atomic.AddUint64(&sb.dropped, 1)
+			}
+			return
+		}
+		for {
+			select {
+			case sb.eventChannel <- e:
+				return
+			default:
+			}
+			select {
+			case <-sb.eventChannel:
+				atomic.AddUint64(&sb.dropped, 1)
+			default:
+				// the collector made room in the meantime
+			}
+		}
+	case OverflowSample:
+		if e = sb.sample(e); nil != e {
+			select {
+			case sb.eventChannel <- e:
+				return
+			default:
+			}
+		}
+		atomic.AddUint64(&sb.dropped, 1)
+	default:
+		sb.eventChannel <- e
+	}
+}
+
+// sample returns the event to queue, with the value of counters scaled up
+// by the sampling probability, or nil if the event is sampled out
+func (sb *StatsdBuffer) sample(e event.Event) event.Event {
+	capacity := cap(sb.eventChannel)
+	threshold := capacity / 2
+	queued := len(sb.eventChannel)
+	if queued < threshold || 0 == capacity {
+		return e
+	}
+	p := float64(capacity-queued) / float64(capacity-threshold)
+	if p <= 0 || rand.Float64() >= p {
+		return nil
+	}
+	if inc, ok := e.(*event.Increment); ok && p < 1 {
+		// don't modify the event, it might be the caller's (SendEvents)
+		return &event.Increment{Name: inc.Name, Value: int64(math.Floor(float64(inc.Value)/p + 0.5)), Tags: inc.Tags}
+	}
+	return e
+}
@@ -0,0 +1,157 @@
+package statsd
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quipo/statsd/event"
+)
+
+// blockingClient blocks in SendEvents until unblocked, to simulate a slow server
+type blockingClient struct {
+	NoopClient
+	flushing chan struct{}
+	unblock  chan struct{}
+
+	mu       sync.Mutex
+	counters map[string]int64
+}
+
+func newBlockingClient() *blockingClient {
+	return &blockingClient{
+		flushing: make(chan struct{}, 1),
+		unblock:  make(chan struct{}),
+		counters: make(map[string]int64),
+	}
+}
+
+func (c *blockingClient) SendEvents(events map[string]event.Event) error {
+	select {
+	case c.flushing <- struct{}{}:
+	default:
+	}
+	<-c.unblock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range events {
+		if inc, ok := e.(*event.Increment); ok {
+			c.counters[inc.Name] += inc.Value
+		}
+	}
+	return nil
+}
+
+// newStuckBuffer returns a buffer whose collector is stuck flushing to a blocking client
+func newStuckBuffer(t *testing.T, capacity int, policy OverflowPolicy) (*StatsdBuffer, *blockingClient) {
+	client := newBlockingClient()
+	buffered, err := NewStatsdBufferWithOptions(time.Millisecond, client,
+		WithChannelCapacity(capacity),
+		WithOverflowPolicy(policy),
+		WithVerbose(false),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	buffered.Incr("first", 1)
+	select {
+	case <-client.flushing:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the flush")
+	}
+	return buffered, client
+}
+
+func TestOverflowDrop(t *testing.T) {
+	tests := []struct {
+		policy   OverflowPolicy
+		expected int64
+	}{
+		{OverflowDropNewest, 1 + 2},
+		{OverflowDropOldest, 8 + 16},
+	}
+	for _, tt := range tests {
+		buffered, client := newStuckBuffer(t, 2, tt.policy)
+		for _, v := range []int64{1, 2, 4, 8, 16} {
+			buffered.Incr("b", v) // must not block
+		}
+		if 3 != buffered.Dropped() {
+			t.Errorf("%s: wrong number of dropped events: Expected: 3, Actual: %d", tt.policy, buffered.Dropped())
+		}
+		close(client.unblock)
+		buffered.Close()
+		if actual := client.counters["b"]; tt.expected != actual {
+			t.Errorf("%s: wrong counter: Expected: %d, Actual: %d", tt.policy, tt.expected, actual)
+		}
+	}
+}
+
+func TestOverflowSample(t *testing.T) {
+	buffered, client := newStuckBuffer(t, 100, OverflowSample)
+	for i := 0; i < 1000; i++ {
+		buffered.Incr("b", 1) // must not block
+	}
+	queued := len(buffered.eventChannel)
+	dropped := buffered.Dropped()
+	if queued < 50 || queued > 100 {
+		t.Errorf("wrong number of queued events: %d", queued)
+	}
+	if uint64(1000-queued) != dropped {
+		t.Errorf("wrong number of dropped events: Expected: %d, Actual: %d", 1000-queued, dropped)
+	}
+	close(client.unblock)
+	buffered.Close()
+	// the sampled events count for more than 1
+	if actual := client.counters["b"]; actual <= int64(queued) {
+		t.Errorf("the sampled counters were not scaled: total %d for %d events", actual, queued)
+	}
+}
+
+func TestOverflowBlock(t *testing.T) {
+	buffered, client := newStuckBuffer(t, 1, OverflowBlock)
+	buffered.Incr("b", 1)
+	done := make(chan struct{})
+	go func() {
+		buffered.Incr("b", 2) // blocks until the flush completes
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Error("the send should block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(client.unblock)
+	<-done
+	buffered.Close()
+	if 0 != buffered.Dropped() || 3 != client.counters["b"] {
+		t.Errorf("unexpected result: dropped %d, counter %d", buffered.Dropped(), client.counters["b"])
+	}
+}
+
+func TestOverflowDropOldestUnbuffered(t *testing.T) {
+	if _, err := NewStatsdBufferWithOptions(time.Hour, &flakyClient{},
+		WithChannelCapacity(0),
+		WithOverflowPolicy(OverflowDropOldest),
+	); nil == err {
+		t.Error("expected an error without a channel capacity")
+	}
+
+	// set afterwards: the new events are dropped instead
+	buffered, client := newStuckBuffer(t, 0, OverflowBlock)
+	buffered.Overflow = OverflowDropOldest
+	done := make(chan struct{})
+	go func() {
+		buffered.Incr("b", 1) // must not block, nor spin
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the send blocked")
+	}
+	if 1 != buffered.Dropped() {
+		t.Errorf("wrong number of dropped events: Expected: 1, Actual: %d", buffered.Dropped())
+	}
+	close(client.unblock)
+	buffered.Close()
+}
@@ -0,0 +1,246 @@
+// Package parser parses the StatsD line protocol ("name:value|type|@rate|#tags")
+// into the events of the github.com/quipo/statsd/event package.
+//
+// Counters sent with a sample rate are scaled by 1/rate, as a StatsD server would do,
+// and the raw values of histograms and distributions keep the sample rate as their Count.
+//
+// Metric names can contain ':', except the names of sets, whose values can contain it instead.
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quipo/statsd/event"
+)
+
+// metric types, with their description for the error messages
+var types = map[string]string{
+	"c":  "counter",
+	"g":  "gauge",
+	"ms": "timing",
+	"a":  "absolute",
+	"t":  "total",
+	"s":  "set",
+	"h":  "histogram",
+	"d":  "distribution",
+}
+
+// Error describes a malformed line
+type Error struct {
+	Line  int    // line number in the packet, starting from 1
+	Pos   int    // byte offset of the error in the line
+	Input string // the malformed line
+	Msg   string
+}
+
+// Error returns a description of the error and its position
+func (e *Error) Error() string {
+	return fmt.Sprintf("statsd parser: line %d, offset %d: %s in %q", e.Line, e.Pos, e.Msg, e.Input)
+}
+
+// Errors is the list of malformed lines in a packet
+type Errors []*Error
+
+// Error returns the description of all the errors
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Parse parses all the newline-separated lines in a packet, skipping the empty ones.
+// The events of the valid lines are always returned; if any line is malformed,
+// the error is of type Errors.
+func Parse(packet []byte) ([]event.Event, error) {
+	var events []event.Event
+	var errs Errors
+	for i, line := range strings.Split(string(packet), "\n") {
+		if line = strings.TrimSpace(line); "" == line {
+			continue
+		}
+		e, err := parseLine(line, i+1)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		events = append(events, e)
+	}
+	if len(errs) > 0 {
+		return events, errs
+	}
+	return events, nil
+}
+
+// ParseLine parses a single line. If the line is malformed, the error is of type *Error.
+func ParseLine(line string) (event.Event, error) {
+	e, err := parseLine(line, 1)
+	if err != nil {
+		return nil, err // avoid returning a non-nil error interface holding a nil *Error
+	}
+	return e, nil
+}
+
+// Fields are the sections of a line, before its value is converted to an event
+type Fields struct {
+	Name       string
+	Value      string  // raw value, e.g. "5", "+2.5" or "user1"
+	Type       string  // e.g. "c", "g", "ms", "s", "h", "d", "a", "t"
+	SampleRate float64 // 1 if not sent
+	Tags       []string
+}
+
+// SplitLine splits a single line into its sections, validating all of them but the value.
+// If the line is malformed, the error is of type *Error.
+func SplitLine(line string) (Fields, error) {
+	f, err := splitLine(line, 1)
+	if err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+func parseLine(line string, n int) (event.Event, *Error) {
+	f, err := splitLine(line, n)
+	if err != nil {
+		return nil, err
+	}
+	e := newEvent(f.Name, f.Value, f.Type, f.SampleRate)
+	if nil == e {
+		return nil, &Error{Line: n, Pos: len(f.Name) + 1, Input: line, Msg: fmt.Sprintf("invalid %s value %q", types[f.Type], f.Value)}
+	}
+	if len(f.Tags) > 0 {
+		e.SetTags(f.Tags)
+	}
+	return e, nil
+}
+
+func splitLine(line string, n int) (Fields, *Error) {
+	f := Fields{SampleRate: 1}
+	fail := func(pos int, format string, args ...interface{}) *Error {
+		return &Error{Line: n, Pos: pos, Input: line, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	end := strings.IndexByte(line, '|')
+	if end < 0 {
+		return f, fail(len(line), "missing metric type")
+	}
+	sections := strings.Split(line[end+1:], "|")
+	typ := sections[0]
+	// metric names can contain ':', so look for the last one before the type,
+	// except for sets: their values (e.g. "urn:user:1") can contain it instead
+	colon := strings.LastIndexByte(line[:end], ':')
+	if "s" == typ {
+		colon = strings.IndexByte(line[:end], ':')
+	}
+	if colon < 0 {
+		return f, fail(0, "missing ':' between metric name and value")
+	}
+	if 0 == colon {
+		return f, fail(0, "empty metric name")
+	}
+	f.Name, f.Value = line[:colon], line[colon+1:end]
+	if "" == f.Value {
+		return f, fail(colon+1, "empty value")
+	}
+
+	if "" == typ {
+		return f, fail(end+1, "missing metric type")
+	}
+	if _, ok := types[typ]; !ok {
+		return f, fail(end+1, "unknown metric type %q", typ)
+	}
+	f.Type = typ
+	pos := end + 1 + len(typ) // offset of the next section
+
+	for _, section := range sections[1:] {
+		pos++ // the '|'
+		switch {
+		case strings.HasPrefix(section, "@"):
+			r, err := strconv.ParseFloat(section[1:], 64)
+			if err != nil || r <= 0 || r > 1 {
+				return f, fail(pos, "invalid sample rate %q", section[1:])
+			}
+			f.SampleRate = r
+		case strings.HasPrefix(section, "#"):
+			if "" == section[1:] {
+				return f, fail(pos, "empty tags")
+			}
+			f.Tags = strings.Split(section[1:], ",")
+			for _, tag := range f.Tags {
+				if "" == tag {
+					return f, fail(pos, "empty tag in %q", section)
+				}
+			}
+		default:
+			return f, fail(pos, "unexpected section %q", section)
+		}
+		pos += len(section)
+	}
+	return f, nil
+}
+
+// newEvent creates the event for a parsed line, or returns nil if the value is not valid for the type
+func newEvent(name string, value string, typ string, rate float64) event.Event {
+	i, ierr := strconv.ParseInt(value, 10, 64)
+	f, ferr := strconv.ParseFloat(value, 64)
+	isInt, isFloat := nil == ierr, nil == ferr && !math.IsNaN(f) && !math.IsInf(f, 0)
+
+	switch typ {
+	case "c":
+		if isInt {
+			return &event.Increment{Name: name, Value: int64(math.Floor(float64(i)/rate + 0.5))}
+		}
+	case "g":
+		delta := strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-")
+		switch {
+		case isInt && delta:
+			return &event.GaugeDelta{Name: name, Value: i}
+		case isInt:
+			return &event.Gauge{Name: name, Value: i}
+		case isFloat && delta:
+			return &event.FGaugeDelta{Name: name, Value: f}
+		case isFloat:
+			return &event.FGauge{Name: name, Value: f}
+		}
+	case "ms":
+		switch {
+		case isInt:
+			return event.NewTiming(name, i)
+		case isFloat:
+			return event.NewPrecisionTiming(name, time.Duration(f*float64(time.Millisecond)))
+		}
+	case "a":
+		switch {
+		case isInt:
+			return &event.Absolute{Name: name, Values: []int64{i}}
+		case isFloat:
+			return &event.FAbsolute{Name: name, Values: []float64{f}}
+		}
+	case "t":
+		if isInt {
+			return &event.Total{Name: name, Value: i}
+		}
+	case "s":
+		return event.NewSet(name, value)
+	case "h":
+		if isFloat {
+			return &event.Histogram{Name: name, Values: []float64{f}, Count: sampledCount(rate)}
+		}
+	case "d":
+		if isFloat {
+			return &event.Distribution{Name: name, Values: []float64{f}, Count: sampledCount(rate)}
+		}
+	}
+	return nil
+}
+
+// sampledCount returns the number of values a sampled value stands for
+func sampledCount(rate float64) int64 {
+	return int64(math.Floor(1/rate + 0.5))
+}
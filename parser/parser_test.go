@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/quipo/statsd/event"
+)
+
+func TestParseLine(t *testing.T) {
+	tt := []struct {
+		line     string
+		expected event.Event
+	}{
+		{"a:b:c:5|c", &event.Increment{Name: "a:b:c", Value: 5}},
+		{"req:1|c|@0.1", &event.Increment{Name: "req", Value: 10}},
+		{"req:1|c|#env:prod,route:/api", &event.Increment{Name: "req", Value: 1, Tags: []string{"env:prod", "route:/api"}}},
+		{"queue:5|g", &event.Gauge{Name: "queue", Value: 5}},
+		{"queue:-5|g", &event.GaugeDelta{Name: "queue", Value: -5}},
+		{"queue:+5|g", &event.GaugeDelta{Name: "queue", Value: 5}},
+		{"load:0.5|g", &event.FGauge{Name: "load", Value: 0.5}},
+		{"load:+0.5|g", &event.FGaugeDelta{Name: "load", Value: 0.5}},
+		{"latency:12|ms", event.NewTiming("latency", 12)},
+		{"latency:0.5|ms|@0.5|#env:prod", event.NewPrecisionTiming("latency", 500*time.Microsecond, "env:prod")},
+		{"abs:3|a", &event.Absolute{Name: "abs", Values: []int64{3}}},
+		{"abs:3.5|a", &event.FAbsolute{Name: "abs", Values: []float64{3.5}}},
+		{"reads:100|t", &event.Total{Name: "reads", Value: 100}},
+		{"users:alice|s", event.NewSet("users", "alice")},
+		{"users:urn:user:1|s|#env:prod", event.NewSet("users", "urn:user:1", "env:prod")},
+		{"size:2.5|h|@0.25", &event.Histogram{Name: "size", Values: []float64{2.5}, Count: 4}},
+		{"size:2.5|d", &event.Distribution{Name: "size", Values: []float64{2.5}, Count: 1}},
+	}
+	for _, tc := range tt {
+		actual, err := ParseLine(tc.line)
+		if err != nil {
+			t.Errorf("unexpected error parsing %q: %s", tc.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(tc.expected, actual) {
+			t.Errorf("wrong event parsed from %q: Expected: %v, Actual: %v", tc.line, tc.expected, actual)
+		}
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	tt := []struct {
+		line string
+		pos  int
+		msg  string
+	}{
+		{"req", 3, "missing metric type"},
+		{"req1|c", 0, "missing ':' between metric name and value"},
+		{":1|c", 0, "empty metric name"},
+		{"req:|c", 4, "empty value"},
+		{"req:1|", 6, "missing metric type"},
+		{"req:1|x", 6, `unknown metric type "x"`},
+		{"req:1.5|c", 4, `invalid counter value "1.5"`},
+		{"queue:abc|g", 6, `invalid gauge value "abc"`},
+		{"req:1|c|@2", 8, `invalid sample rate "2"`},
+		{"req:1|c|#", 8, "empty tags"},
+		{"req:1|c|#a,,b", 8, `empty tag in "#a,,b"`},
+		{"req:1|c|@0.5|foo", 13, `unexpected section "foo"`},
+	}
+	for _, tc := range tt {
+		_, err := ParseLine(tc.line)
+		perr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("expected a *Error parsing %q, got %T %v", tc.line, err, err)
+			continue
+		}
+		if perr.Pos != tc.pos || perr.Msg != tc.msg || perr.Line != 1 || perr.Input != tc.line {
+			t.Errorf("wrong error parsing %q: Expected: %d %s, Actual: %d %s", tc.line, tc.pos, tc.msg, perr.Pos, perr.Msg)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	packet := []byte("req:1|c\n\nqueue:x|g\r\nusers:alice|s\n")
+	events, err := Parse(packet)
+
+	expected := []event.Event{
+		&event.Increment{Name: "req", Value: 1},
+		event.NewSet("users", "alice"),
+	}
+	if !reflect.DeepEqual(expected, events) {
+		t.Errorf("wrong events parsed: Expected: %v, Actual: %v", expected, events)
+	}
+
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("expected one error, got %T %v", err, err)
+	}
+	if errs[0].Line != 3 {
+		t.Errorf("wrong line number in the error: expected 3, actual %d", errs[0].Line)
+	}
+}
+
+func TestParseStats(t *testing.T) {
+	// the events' stats can be parsed back into equivalent events
+	events := []event.Event{
+		&event.Increment{Name: "req", Value: 5, Tags: []string{"env:prod"}},
+		&event.Gauge{Name: "queue", Value: 3},
+		&event.Total{Name: "reads", Value: 7},
+		event.NewSet("users", "bob"),
+	}
+	for _, e := range events {
+		for _, stat := range e.Stats() {
+			actual, err := ParseLine(stat)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			if !reflect.DeepEqual(e, actual) {
+				t.Errorf("wrong event parsed from %q: Expected: %v, Actual: %v", stat, e, actual)
+			}
+		}
+	}
+}
+
+func TestSplitLine(t *testing.T) {
+	// the values are not validated
+	actual, err := SplitLine("req:abc|c|@0.5|#env:prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Fields{Name: "req", Value: "abc", Type: "c", SampleRate: 0.5, Tags: []string{"env:prod"}}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("wrong fields: Expected: %v, Actual: %v", expected, actual)
+	}
+	if _, err = SplitLine("req:1|c|@0"); nil == err {
+		t.Error("expected an error for a sample rate of 0")
+	}
+}
@@ -0,0 +1,187 @@
+package statsd
+
+import (
+	"math/rand"
+	"net"
+	"time"
+)
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// ConnState is the state of the connection of a StatsdClient to the StatsD server
+type ConnState int
+
+// connection states, as notified to ReconnectPolicy.OnStateChange
+const (
+	StateDisconnected ConnState = iota // a write failed, or the client is not connected yet
+	StateReconnecting                  // an attempt to reconnect failed, retrying after a backoff
+	StateConnected                     // (re)connected
+)
+
+// String returns the name of the state
+func (s ConnState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateConnected:
+		return "connected"
+	}
+	return "unknown"
+}
+
+// ReconnectPolicy configures the automatic reconnection of a StatsdClient
+// using a TCP or Unix socket. When a write fails, the connection is closed
+// and re-established in the background, waiting an exponentially increasing
+// delay (from MinBackoff to MaxBackoff) between the attempts.
+// Until the connection is back, all the sends fail immediately.
+type ReconnectPolicy struct {
+	MinBackoff time.Duration // delay before the first attempt (default 100ms)
+	MaxBackoff time.Duration // maximum delay between the attempts (default 30s)
+	// Jitter randomises each delay by up to this fraction (0-1),
+	// so that many clients don't reconnect to a restarted server at the same time
+	Jitter float64
+	// OnStateChange, if set, is called on every change of connection state,
+	// with the error that caused it (nil when connected)
+	OnStateChange func(state ConnState, err error)
+}
+
+// NewReconnectPolicy is a factory for a ReconnectPolicy with the default backoff
+// (100ms to 30s, with 20% of jitter)
+func NewReconnectPolicy() *ReconnectPolicy {
+	return &ReconnectPolicy{
+		MinBackoff: defaultMinBackoff,
+		MaxBackoff: defaultMaxBackoff,
+		Jitter:     0.2,
+	}
+}
+
+// backoff returns the delay before the given attempt (starting from 0)
+func (p *ReconnectPolicy) backoff(attempt int) time.Duration {
+	min, max := p.MinBackoff, p.MaxBackoff
+	if min <= 0 {
+		min = defaultMinBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	if max < min {
+		max = min
+	}
+	d := min
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		// +/- jitter, uniformly distributed
+		d = time.Duration(float64(d) * (1 + jitter*(2*rand.Float64()-1)))
+	}
+	return d
+}
+
+func (p *ReconnectPolicy) notify(state ConnState, err error) {
+	if nil != p.OnStateChange {
+		p.OnStateChange(state, err)
+	}
+}
+
+// State returns the current state of the connection
+func (c *StatsdClient) State() ConnState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	switch {
+	case nil != c.conn:
+		return StateConnected
+	case c.reconnecting:
+		return StateReconnecting
+	}
+	return StateDisconnected
+}
+
+// writeFailed closes the connection after a failed write and, if a ReconnectPolicy
+// is set, starts reconnecting in the background
+func (c *StatsdClient) writeFailed(conn net.Conn, err error) {
+	c.mu.Lock()
+	p := c.Reconnect
+	if nil == p || !c.sockType.isStream() || c.conn != conn || c.closed {
+		// UDP and unixgram writes don't need a new connection, or another
+		// goroutine is already reconnecting
+		c.mu.Unlock()
+		return
+	}
+	c.conn = nil
+	c.startReconnect(p)
+	c.mu.Unlock()
+
+	conn.Close()
+	handleError(c.OnError, c.Logger, &Error{Kind: ErrorConnection, Err: err},
+		"Connection to", c.addr, "lost, reconnecting:", err)
+	p.notify(StateDisconnected, err)
+}
+
+// startReconnect starts the reconnection loop. Must be called with the lock held.
+func (c *StatsdClient) startReconnect(p *ReconnectPolicy) {
+	c.stopReconnect()
+	c.reconnecting = true
+	c.done = make(chan struct{})
+	go c.reconnect(p, c.sockType, c.done)
+}
+
+// stopReconnect stops the reconnection loop, if any. Must be called with the lock held.
+func (c *StatsdClient) stopReconnect() {
+	if nil != c.done {
+		close(c.done)
+		c.done = nil
+	}
+	c.reconnecting = false
+}
+
+// reconnect tries to dial the server, with exponential backoff, until it succeeds
+// or the loop is stopped (by Close or by creating a new socket)
+func (c *StatsdClient) reconnect(p *ReconnectPolicy, sockType socketType, done chan struct{}) {
+	for attempt := 0; ; attempt++ {
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		conn, err := net.DialTimeout(string(sockType), c.addr, c.timeout())
+		if nil != err {
+			handleError(c.OnError, c.Logger, &Error{Kind: ErrorConnection, Err: err})
+			p.notify(StateReconnecting, err)
+			continue
+		}
+
+		c.mu.Lock()
+		select {
+		case <-done:
+			// stopped while dialing
+			c.mu.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		c.conn = conn
+		c.done = nil
+		c.reconnecting = false
+		c.mu.Unlock()
+
+		c.Logger.Println("Reconnected to", c.addr)
+		p.notify(StateConnected, nil)
+		return
+	}
+}
@@ -0,0 +1,153 @@
+package statsd
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+// serveLines accepts a single connection and sends all the lines received to ch
+func serveLines(ln net.Listener, ch chan string) <-chan net.Conn {
+	conns := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if nil != err {
+			close(conns)
+			return
+		}
+		conns <- conn
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			ch <- scanner.Text()
+		}
+	}()
+	return conns
+}
+
+func expectLine(t *testing.T, ch chan string, expected string) {
+	t.Helper()
+	select {
+	case line := <-ch:
+		if line != expected {
+			t.Errorf("unexpected line: Expected: %q, Actual: %q", expected, line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %q", expected)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	lines := make(chan string, 10)
+	conns := serveLines(ln, lines)
+
+	states := make(chan ConnState, 100)
+	client := NewStatsdClient(addr, "test.")
+	client.Reconnect = &ReconnectPolicy{
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 40 * time.Millisecond,
+		Jitter:     0.1,
+		OnStateChange: func(state ConnState, err error) {
+			states <- state
+		},
+	}
+	if err = client.CreateTCPSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	client.Incr("a", 1)
+	expectLine(t, lines, "test.a:1|c")
+
+	// restart the server: the writes start failing once the client notices
+	ln.Close()
+	(<-conns).Close()
+	for i := 0; i < 100 && nil == err; i++ {
+		err = client.Incr("b", 1)
+		time.Sleep(5 * time.Millisecond)
+	}
+	if nil == err {
+		t.Fatal("expected a write error after the server shut down")
+	}
+	if state := <-states; StateDisconnected != state {
+		t.Errorf("unexpected state: Expected: %s, Actual: %s", StateDisconnected, state)
+	}
+	if err = client.Incr("b", 1); errNotConnected != err {
+		t.Errorf("expected the sends to fail while reconnecting, got %v", err)
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	serveLines(ln, lines)
+
+	timeout := time.After(2 * time.Second)
+	for state := StateReconnecting; StateConnected != state; {
+		select {
+		case state = <-states:
+		case <-timeout:
+			t.Fatal("timed out waiting to reconnect")
+		}
+	}
+	if StateConnected != client.State() {
+		t.Errorf("unexpected state: Expected: %s, Actual: %s", StateConnected, client.State())
+	}
+	client.Incr("c", 1)
+	expectLine(t, lines, "test.c:1|c")
+}
+
+func TestReconnectStoppedByClose(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close() // nothing listening
+
+	client := NewStatsdClient(addr, "test.")
+	client.Reconnect = &ReconnectPolicy{MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	if err = client.CreateTCPSocket(); nil == err {
+		t.Fatal("expected a connection error")
+	}
+	if StateReconnecting != client.State() {
+		t.Errorf("unexpected state: Expected: %s, Actual: %s", StateReconnecting, client.State())
+	}
+	client.Close()
+	if StateDisconnected != client.State() {
+		t.Errorf("unexpected state: Expected: %s, Actual: %s", StateDisconnected, client.State())
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	p := &ReconnectPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for attempt, d := range expected {
+		if actual := p.backoff(attempt); d != actual {
+			t.Errorf("wrong backoff for attempt %d: Expected: %s, Actual: %s", attempt, d, actual)
+		}
+	}
+	if actual := p.backoff(1000); time.Second != actual {
+		t.Errorf("wrong backoff for attempt 1000: Expected: %s, Actual: %s", time.Second, actual)
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if actual := p.backoff(1); actual < 100*time.Millisecond || actual > 300*time.Millisecond {
+			t.Errorf("backoff with jitter out of range: %s", actual)
+		}
+	}
+}
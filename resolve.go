@@ -0,0 +1,98 @@
+package statsd
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// lookupHost resolves a hostname to its IP addresses (replaced in the tests)
+var lookupHost = net.DefaultResolver.LookupHost
+
+// startResolve starts re-resolving the address at the given interval,
+// unless it's already an IP address. Must be called with the lock held.
+func (c *StatsdClient) startResolve(interval time.Duration) {
+	host, _, err := net.SplitHostPort(c.addr)
+	if nil != err || nil != net.ParseIP(host) {
+		return
+	}
+	c.resolveDone = make(chan struct{})
+	go c.resolveLoop(interval, c.resolveDone)
+}
+
+// stopResolve stops re-resolving the address, if running. Must be called with the lock held.
+func (c *StatsdClient) stopResolve() {
+	if nil != c.resolveDone {
+		close(c.resolveDone)
+		c.resolveDone = nil
+	}
+}
+
+func (c *StatsdClient) resolveLoop(interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.resolve(done); nil != err {
+				handleError(c.OnError, c.Logger, &Error{Kind: ErrorConnection, Err: err}, "Error resolving", c.addr, err)
+			}
+		}
+	}
+}
+
+// resolve looks up the hostname again and, if the IP address the client is sending to
+// is not one of its addresses any more, replaces the connection with one to the new address
+func (c *StatsdClient) resolve(done chan struct{}) error {
+	host, port, err := net.SplitHostPort(c.addr)
+	if nil != err {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout())
+	defer cancel()
+	addrs, err := lookupHost(ctx, host)
+	if nil != err {
+		return err
+	}
+	if 0 == len(addrs) {
+		return &net.DNSError{Err: "no addresses", Name: host}
+	}
+
+	c.mu.RLock()
+	old := c.conn
+	c.mu.RUnlock()
+	if nil != old {
+		if current, ok := old.RemoteAddr().(*net.UDPAddr); ok {
+			for _, addr := range addrs {
+				if ip := net.ParseIP(addr); nil != ip && ip.Equal(current.IP) {
+					return nil // still valid
+				}
+			}
+		}
+	}
+
+	conn, err := net.DialTimeout(string(udpSocket), net.JoinHostPort(addrs[0], port), c.timeout())
+	if nil != err {
+		return err
+	}
+
+	c.mu.Lock()
+	select {
+	case <-done:
+		// closed, or a new socket was created, while resolving
+		c.mu.Unlock()
+		conn.Close()
+		return nil
+	default:
+	}
+	c.conn = conn
+	c.mu.Unlock()
+
+	if nil != old {
+		old.Close()
+	}
+	c.Logger.Println("Address of", c.addr, "changed, now sending to", conn.RemoteAddr())
+	return nil
+}
@@ -0,0 +1,134 @@
+package statsd
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readPackets sends all the packets received on conn to ch
+func readPackets(conn *net.UDPConn, ch chan string) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := conn.Read(buf)
+		if nil != err {
+			return
+		}
+		ch <- string(buf[:n])
+	}
+}
+
+// sendUntil sends a metric until the expected packet is received
+func sendUntil(t *testing.T, client *StatsdClient, stat string, ch chan string) {
+	t.Helper()
+	expected := "test." + stat + ":1|c"
+	timeout := time.After(2 * time.Second)
+	for {
+		client.Incr(stat, 1)
+		select {
+		case packet := <-ch:
+			if strings.Contains(packet, expected) {
+				return
+			}
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatalf("timed out waiting for %q", expected)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	connA, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer connA.Close()
+	port := connA.LocalAddr().(*net.UDPAddr).Port
+	connB, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: port})
+	if nil != err {
+		t.Skip("cannot listen on 127.0.0.2:", err)
+	}
+	defer connB.Close()
+
+	chA, chB := make(chan string, 100), make(chan string, 100)
+	go readPackets(connA, chA)
+	go readPackets(connB, chB)
+
+	var mu sync.Mutex
+	ip := "127.0.0.1"
+	defer func(lookup func(context.Context, string) ([]string, error)) {
+		lookupHost = lookup
+	}(lookupHost)
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return []string{ip}, nil
+	}
+
+	client := NewStatsdClient("localhost:"+strconv.Itoa(port), "test.")
+	client.ResolveInterval = 10 * time.Millisecond
+	if err = client.CreateSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	sendUntil(t, client, "a", chA)
+
+	// the service moved to another IP address
+	mu.Lock()
+	ip = "127.0.0.2"
+	mu.Unlock()
+	sendUntil(t, client, "b", chB)
+}
+
+func TestResolveIPAddress(t *testing.T) {
+	client := NewStatsdClient("127.0.0.1:1201", "test.")
+	client.ResolveInterval = 10 * time.Millisecond
+	if err := client.CreateSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if nil != client.resolveDone {
+		t.Error("IP addresses should not be resolved again")
+	}
+}
+
+func TestStalledWriteDoesNotBlockClose(t *testing.T) {
+	client, err := NewStatsdClientWithOptions("127.0.0.1:8125", "test.", WithDialTimeout(time.Hour))
+	if nil != err {
+		t.Fatal(err)
+	}
+	// nobody reads the other end: the writes block
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	client.conn = conn
+	client.sockType = tcpSocket
+
+	written := make(chan error, 1)
+	go func() {
+		written <- client.Incr("req", 1)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		client.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked by a stalled write")
+	}
+	select {
+	case err = <-written:
+		if nil == err {
+			t.Error("expected the stalled write to fail")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the stalled write did not fail after Close")
+	}
+}
@@ -0,0 +1,187 @@
+package statsd
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/quipo/statsd/event"
+)
+
+// shardKey identifies an aggregated event in a shard, without allocating a "type|key|tags" string
+type shardKey struct {
+	typ  string
+	name string
+	tags string
+}
+
+// counter is the value of an Increment (added) or of a Gauge (replaced), updated atomically
+type counter struct {
+	value int64
+	gauge bool
+	name  string
+	tags  []string
+}
+
+// event returns the aggregated event
+func (c *counter) event() event.Event {
+	if c.gauge {
+		return &event.Gauge{Name: c.name, Value: atomic.LoadInt64(&c.value), Tags: c.tags}
+	}
+	return &event.Increment{Name: c.name, Value: atomic.LoadInt64(&c.value), Tags: c.tags}
+}
+
+// shard aggregates the events with the keys hashing to it. Increments and Gauges
+// are updated atomically under the read lock, the other events under the write lock.
+// The write lock is also taken to swap the maps at flush time.
+type shard struct {
+	received uint64 // events passed to the metric functions, accessed atomically, first for 64-bit alignment
+	mu       sync.RWMutex
+	counters map[shardKey]*counter
+	events   map[shardKey]event.Event
+	// admit, if set, applies the cardinality limits to the new keys (see StatsdBuffer.admit)
+	admit func(name string) (string, bool)
+}
+
+func newShard(admit func(name string) (string, bool)) *shard {
+	return &shard{
+		counters: make(map[shardKey]*counter),
+		events:   make(map[shardKey]event.Event),
+		admit:    admit,
+	}
+}
+
+// shards split the aggregation of the events of a StatsdBuffer by key, so that
+// concurrent goroutines don't contend on a single channel and collector
+type shards []*shard
+
+func newShards(n int, admit func(name string) (string, bool)) shards {
+	ret := make(shards, n)
+	for i := range ret {
+		ret[i] = newShard(admit)
+	}
+	return ret
+}
+
+// get returns the shard for a key (FNV-1a hash)
+func (s shards) get(k shardKey) *shard {
+	h := uint32(2166136261)
+	for _, str := range [...]string{k.typ, k.name, k.tags} {
+		for i := 0; i < len(str); i++ {
+			h ^= uint32(str[i])
+			h *= 16777619
+		}
+	}
+	return s[h%uint32(len(s))]
+}
+
+// incr adds to a counter
+func (s shards) incr(name string, value int64, tags []string) {
+	s.counter(shardKey{typ: "Increment", name: name, tags: event.TagKey(tags)}, false, value, tags, true)
+}
+
+// gauge replaces the value of a gauge
+func (s shards) gauge(name string, value int64, tags []string) {
+	s.counter(shardKey{typ: "Gauge", name: name, tags: event.TagKey(tags)}, true, value, tags, true)
+}
+
+// counter updates a counter, creating it if the cardinality limits admit its key
+// (limited is false for the keys the events beyond the limits are folded into)
+func (s shards) counter(k shardKey, gauge bool, value int64, tags []string, limited bool) {
+	sh := s.get(k)
+	if limited {
+		atomic.AddUint64(&sh.received, 1)
+	}
+	sh.mu.RLock()
+	c, ok := sh.counters[k]
+	if ok {
+		c.update(value)
+		sh.mu.RUnlock()
+		return
+	}
+	sh.mu.RUnlock()
+
+	sh.mu.Lock()
+	if c, ok = sh.counters[k]; !ok {
+		if nil != sh.admit && limited {
+			if name, admitted := sh.admit(k.name); !admitted {
+				sh.mu.Unlock()
+				if "" != name {
+					s.counter(shardKey{typ: k.typ, name: name}, gauge, value, nil, false)
+				}
+				return
+			}
+		}
+		c = &counter{gauge: gauge, name: k.name, tags: tags}
+		sh.counters[k] = c
+	}
+	c.update(value)
+	sh.mu.Unlock()
+}
+
+func (c *counter) update(value int64) {
+	if c.gauge {
+		atomic.StoreInt64(&c.value, value)
+	} else {
+		atomic.AddInt64(&c.value, value)
+	}
+}
+
+// aggregate any other event into its shard, if the cardinality limits admit its key
+func (s shards) aggregate(e event.Event) error {
+	return s.aggregateLimited(e, true)
+}
+
+func (s shards) aggregateLimited(e event.Event, limited bool) error {
+	k := shardKey{typ: e.TypeString(), name: e.Key(), tags: event.TagKey(e.GetTags())}
+	sh := s.get(k)
+	if limited {
+		atomic.AddUint64(&sh.received, 1)
+	}
+	sh.mu.Lock()
+	if e2, ok := sh.events[k]; ok {
+		defer sh.mu.Unlock()
+		return e2.Update(e)
+	}
+	if nil != sh.admit && limited {
+		if name, admitted := sh.admit(k.name); !admitted {
+			sh.mu.Unlock()
+			if "" == name {
+				return nil
+			}
+			if folded := foldedCopy(e, name); nil != folded {
+				return s.aggregateLimited(folded, false)
+			}
+			return nil
+		}
+	}
+	sh.events[k] = e
+	sh.mu.Unlock()
+	return nil
+}
+
+// received returns the number of events passed to the metric functions, in all the shards
+func (s shards) received() uint64 {
+	var ret uint64
+	for _, sh := range s {
+		ret += atomic.LoadUint64(&sh.received)
+	}
+	return ret
+}
+
+// drain removes all the aggregated events from the shards, passing them to fn
+func (s shards) drain(fn func(e event.Event)) {
+	for _, sh := range s {
+		sh.mu.Lock()
+		counters, events := sh.counters, sh.events
+		sh.counters = make(map[shardKey]*counter, len(counters))
+		sh.events = make(map[shardKey]event.Event, len(events))
+		sh.mu.Unlock()
+
+		for _, c := range counters {
+			fn(c.event())
+		}
+		for _, e := range events {
+			fn(e)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package statsd
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quipo/statsd/event"
+)
+
+func TestShardedBuffer(t *testing.T) {
+	client := NewStatsdClient("127.0.0.1:1201", "test.")
+	mock := &MockNetConn{}
+	client.conn = mock
+	buffered, err := NewStatsdBufferWithOptions(time.Hour, client, WithShards(4), WithVerbose(false))
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= 100; i++ {
+				buffered.Incr("req", 2, "env:prod")
+				buffered.Decr("req", 1, "env:prod")
+				buffered.Incr("req", 1)
+				buffered.Gauge("queue", 5)
+				buffered.Timing("latency", int64(i))
+				buffered.Set("users", "user"+strconv.Itoa(i%10))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// counted by the shards, not by the shared counter
+	if received := buffered.Stats().EventsReceived; 8*100*6 != received {
+		t.Errorf("wrong number of events received: Expected: %d, Actual: %d", 8*100*6, received)
+	}
+	if 0 != buffered.stats.EventsReceived {
+		t.Errorf("the shared counter was updated: %d", buffered.stats.EventsReceived)
+	}
+
+	if err = buffered.Close(); nil != err {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for _, x := range strings.Split(mock.buf.String(), "\n") {
+		if x = strings.TrimSpace(x); "" != x {
+			actual = append(actual, x)
+		}
+	}
+	sort.Strings(actual)
+	expected := []string{
+		"test.latency.avg:50|ms",
+		"test.latency.count:800|c",
+		"test.latency.max:100|ms",
+		"test.latency.min:1|ms",
+		"test.queue:5|g",
+		"test.req:800|c",
+		"test.req:800|c|#env:prod",
+	}
+	for i := 0; i < 10; i++ {
+		expected = append(expected, "test.users:user"+strconv.Itoa(i)+"|s")
+	}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, actual, actual)
+	}
+}
+
+func TestShardedBufferFlushes(t *testing.T) {
+	client := NewStatsdClient("127.0.0.1:1201", "test.")
+	mock := &MockNetConn{}
+	client.conn = mock
+	buffered, err := NewStatsdBufferWithOptions(time.Hour, client, WithShards(2), WithVerbose(false))
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// the shards are reset at each flush
+	buffered.Incr("req", 1)
+	buffered.Gauge("queue", 5)
+	var n int
+	buffered.shards.drain(func(e event.Event) {
+		n++
+	})
+	if 2 != n {
+		t.Errorf("wrong number of events: Expected: 2, Actual: %d", n)
+	}
+	buffered.Incr("req", 2)
+	n = 0
+	buffered.shards.drain(func(e event.Event) {
+		n++
+		if "req:2|c" != e.Stats()[0] {
+			t.Errorf("unexpected event after the flush: %s", e.String())
+		}
+	})
+	if 1 != n {
+		t.Errorf("wrong number of events after the flush: Expected: 1, Actual: %d", n)
+	}
+	buffered.Close()
+}
+
+// benchmarkBuffer measures the throughput of concurrent Incr calls, which should scale
+// with GOMAXPROCS for a sharded buffer (go test -bench Buffered -cpu 1,2,4,8)
+func benchmarkBuffer(b *testing.B, opts ...Option) {
+	client := NewStatsdClient("127.0.0.1:1201", "test.")
+	client.conn = &MockNetConn{}
+	buffered, err := NewStatsdBufferWithOptions(time.Second, client, append(opts, WithVerbose(false))...)
+	if nil != err {
+		b.Fatal(err)
+	}
+	defer buffered.Close()
+	keys := make([]string, 64)
+	for i := range keys {
+		keys[i] = "metric" + strconv.Itoa(i)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			buffered.Incr(keys[i%len(keys)], 1)
+			i++
+		}
+	})
+}
+
+func BenchmarkBufferedIncr(b *testing.B) {
+	benchmarkBuffer(b)
+}
+
+func BenchmarkBufferedIncrSharded(b *testing.B) {
+	benchmarkBuffer(b, WithShards(64))
+}
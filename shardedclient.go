@@ -0,0 +1,378 @@
+package statsd
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/quipo/statsd/event"
+)
+
+// ShardReplicas is the number of points each server has on the hash ring of a ShardedClient:
+// the more points, the more evenly the metrics are spread across the servers
+var ShardReplicas = 160
+
+// ErrNoServers is returned by a ShardedClient without servers
+var ErrNoServers = errors.New("statsd: no servers to send the stats to")
+
+// hashRing maps keys to nodes with consistent hashing, so that adding or removing
+// a node only moves the keys of that node (about 1/n of them)
+type hashRing struct {
+	points []uint64
+	nodes  map[uint64]string
+}
+
+// hash64 is FNV-1a, with the murmur3 finalizer to spread similar keys evenly on the ring
+func hash64(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func newHashRing(nodes []string) *hashRing {
+	r := &hashRing{nodes: make(map[uint64]string)}
+	for _, node := range nodes {
+		for i := 0; i < ShardReplicas; i++ {
+			p := hash64(node + "-" + strconv.Itoa(i))
+			if _, ok := r.nodes[p]; ok {
+				continue // collision, keep the first one
+			}
+			r.nodes[p] = node
+			r.points = append(r.points, p)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// get returns the node for a key: the first one clockwise from the hash of the key
+func (r *hashRing) get(key string) (string, bool) {
+	if 0 == len(r.points) {
+		return "", false
+	}
+	h := hash64(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.nodes[r.points[i]], true
+}
+
+// ShardedClient sends the metrics to a cluster of StatsD servers, routing each metric
+// by consistent hashing of its prefixed name (regardless of the tags), so that the same
+// metric always lands on the same server, and adding or removing a server only moves
+// the metrics of that server
+type ShardedClient struct {
+	prefix string
+
+	mu       sync.RWMutex
+	ring     *hashRing
+	clients  map[string]*StatsdClient
+	sockType socketType // set once the sockets are created
+}
+
+// NewShardedClient - Factory
+func NewShardedClient(addrs []string, prefix string) *ShardedClient {
+	c := &ShardedClient{
+		prefix:  prefix,
+		clients: make(map[string]*StatsdClient),
+	}
+	for _, addr := range addrs {
+		c.clients[addr] = NewStatsdClient(addr, prefix)
+	}
+	c.ring = newHashRing(addrs)
+	return c
+}
+
+// CreateSocket creates a UDP connection to every StatsD server
+func (c *ShardedClient) CreateSocket() error {
+	return c.dial(udpSocket)
+}
+
+// CreateTCPSocket creates a TCP connection to every StatsD server
+func (c *ShardedClient) CreateTCPSocket() error {
+	return c.dial(tcpSocket)
+}
+
+func (c *ShardedClient) dial(sockType socketType) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sockType = sockType
+	var ret error
+	for _, client := range c.clients {
+		if err := client.dial(sockType); nil != err && nil == ret {
+			ret = err
+		}
+	}
+	return ret
+}
+
+// Close the connections to all the servers
+func (c *ShardedClient) Close() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var ret error
+	for _, client := range c.clients {
+		if err := client.Close(); nil != err && nil == ret {
+			ret = err
+		}
+	}
+	return ret
+}
+
+// AddServer adds a server to the cluster, connecting to it if the sockets
+// were already created. Only the metrics moving to the new server change server.
+// The metrics keep being sent to the other servers while connecting.
+func (c *ShardedClient) AddServer(addr string) error {
+	for {
+		c.mu.RLock()
+		_, ok := c.clients[addr]
+		sockType := c.sockType
+		c.mu.RUnlock()
+		if ok {
+			return nil
+		}
+		client := NewStatsdClient(addr, c.prefix)
+		if "" != sockType {
+			if err := client.dial(sockType); nil != err {
+				return err
+			}
+		}
+
+		c.mu.Lock()
+		_, ok = c.clients[addr]
+		if ok || sockType != c.sockType {
+			// added concurrently, or the sockets were created meanwhile: discard this client
+			c.mu.Unlock()
+			client.Close()
+			if ok {
+				return nil
+			}
+			continue
+		}
+		c.clients[addr] = client
+		c.ring = newHashRing(c.servers())
+		c.mu.Unlock()
+		return nil
+	}
+}
+
+// RemoveServer removes a server from the cluster, closing the connection to it.
+// Only the metrics of the removed server change server.
+func (c *ShardedClient) RemoveServer(addr string) error {
+	c.mu.Lock()
+	client, ok := c.clients[addr]
+	if ok {
+		delete(c.clients, addr)
+		c.ring = newHashRing(c.servers())
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return client.Close()
+}
+
+// Servers returns the addresses of the servers in the cluster, sorted
+func (c *ShardedClient) Servers() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.servers()
+}
+
+func (c *ShardedClient) servers() []string {
+	ret := make([]string, 0, len(c.clients))
+	for addr := range c.clients {
+		ret = append(ret, addr)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// ServerFor returns the address of the server a metric is sent to
+func (c *ShardedClient) ServerFor(stat string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	addr, _ := c.ring.get(c.key(stat))
+	return addr
+}
+
+// key returns the name of a metric as it's sent over the wire
+func (c *ShardedClient) key(stat string) string {
+	return c.prefix + expandGlobal(stat)
+}
+
+// client returns the client of the server a metric is sent to
+func (c *ShardedClient) client(stat string) (*StatsdClient, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	addr, ok := c.ring.get(c.key(stat))
+	if !ok {
+		return nil, ErrNoServers
+	}
+	return c.clients[addr], nil
+}
+
+// Incr - Increment a counter metric. Often used to note a particular event
+func (c *ShardedClient) Incr(stat string, count int64, tags ...string) error {
+	client, err := c.client(stat)
+	if nil != err {
+		return err
+	}
+	return client.Incr(stat, count, tags...)
+}
+
+// Decr - Decrement a counter metric. Often used to note a particular event
+func (c *ShardedClient) Decr(stat string, count int64, tags ...string) error {
+	client, err := c.client(stat)
+	if nil != err {
+		return err
+	}
+	return client.Decr(stat, count, tags...)
+}
+
+// Timing - Track a duration event
+// the time delta must be given in milliseconds
+func (c *ShardedClient) Timing(stat string, delta int64, tags ...string) error {
+	client, err := c.client(stat)
+	if nil != err {
+		return err
+	}
+	return client.Timing(stat, delta, tags...)
+}
+
+// PrecisionTiming - Track a duration event
+// the time delta has to be a duration
+func (c *ShardedClient) PrecisionTiming(stat string, delta time.Duration, tags ...string) error {
+	client, err := c.client(stat)
+	if nil != err {
+		return err
+	}
+	return client.PrecisionTiming(stat, delta, tags...)
+}
+
+// Gauge - Gauges are a constant data type. They are not subject to averaging,
+// and they don’t change unless you change them. That is, once you set a gauge value,
+// it will be a flat line on the graph until you change it again
+func (c *ShardedClient) Gauge(stat string, value int64, tags ...string) error {
+	client, err := c.client(stat)
+	if nil != err {
+		return err
+	}
+	return client.Gauge(stat, value, tags...)
+}
+
+// GaugeDelta records a delta from the previous value (as int64)
+func (c *ShardedClient) GaugeDelta(stat string, value int64, tags ...string) error {
+	client, err := c.client(stat)
+	if nil != err {
+		return err
+	}
+	return client.GaugeDelta(stat, value, tags...)
+}
+
+// FGauge is a Gauge working with float64 values
+func (c *ShardedClient) FGauge(stat string, value float64, tags ...string) error {
+	client, err := c.client(stat)
+	if nil != err {
+		return err
+	}
+	return client.FGauge(stat, value, tags...)
+}
+
+// FGaugeDelta records a delta from the previous value (as float64)
+func (c *ShardedClient) FGaugeDelta(stat string, value float64, tags ...string) error {
+	client, err := c.client(stat)
+	if nil != err {
+		return err
+	}
+	return client.FGaugeDelta(stat, value, tags...)
+}
+
+// Absolute - Send absolute-valued metric (not averaged/aggregated)
+func (c *ShardedClient) Absolute(stat string, value int64, tags ...string) error {
+	client, err := c.client(stat)
+	if nil != err {
+		return err
+	}
+	return client.Absolute(stat, value, tags...)
+}
+
+// FAbsolute - Send absolute-valued floating point metric (not averaged/aggregated)
+func (c *ShardedClient) FAbsolute(stat string, value float64, tags ...string) error {
+	client, err := c.client(stat)
+	if nil != err {
+		return err
+	}
+	return client.FAbsolute(stat, value, tags...)
+}
+
+// Total - Send a metric that is continously increasing, e.g. read operations since boot
+func (c *ShardedClient) Total(stat string, value int64, tags ...string) error {
+	client, err := c.client(stat)
+	if nil != err {
+		return err
+	}
+	return client.Total(stat, value, tags...)
+}
+
+// Set - Send a value to be counted as unique per flush interval (e.g. unique users)
+func (c *ShardedClient) Set(stat string, value string, tags ...string) error {
+	client, err := c.client(stat)
+	if nil != err {
+		return err
+	}
+	return client.Set(stat, value, tags...)
+}
+
+// Histogram - Send a value to be aggregated into a statistical distribution by the server (per host)
+func (c *ShardedClient) Histogram(stat string, value float64, tags ...string) error {
+	client, err := c.client(stat)
+	if nil != err {
+		return err
+	}
+	return client.Histogram(stat, value, tags...)
+}
+
+// Distribution - Send a value to be aggregated into a statistical distribution
+// by the server (globally, across all hosts)
+func (c *ShardedClient) Distribution(stat string, value float64, tags ...string) error {
+	client, err := c.client(stat)
+	if nil != err {
+		return err
+	}
+	return client.Distribution(stat, value, tags...)
+}
+
+// SendEvents - Sends stats from all the event objects, each one to the server of its key.
+// The events are sent to all the servers even if some fail, returning the first error.
+func (c *ShardedClient) SendEvents(events map[string]event.Event) error {
+	byServer := make(map[*StatsdClient]map[string]event.Event)
+	for k, e := range events {
+		client, err := c.client(e.Key())
+		if nil != err {
+			return err
+		}
+		if _, ok := byServer[client]; !ok {
+			byServer[client] = make(map[string]event.Event)
+		}
+		byServer[client][k] = e
+	}
+	var ret error
+	for client, events := range byServer {
+		if err := client.SendEvents(events); nil != err && nil == ret {
+			ret = err
+		}
+	}
+	return ret
+}
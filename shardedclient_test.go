@@ -0,0 +1,161 @@
+package statsd
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quipo/statsd/event"
+	"github.com/quipo/statsd/statsdtest"
+)
+
+var _ Statsd = (*ShardedClient)(nil)
+
+func TestHashRing(t *testing.T) {
+	nodes := []string{"10.0.0.1:8125", "10.0.0.2:8125", "10.0.0.3:8125", "10.0.0.4:8125"}
+	before := newHashRing(nodes)
+
+	keys := make([]string, 10000)
+	counts := make(map[string]int)
+	for i := range keys {
+		keys[i] = "myproject.metric" + strconv.Itoa(i)
+		node, _ := before.get(keys[i])
+		counts[node]++
+	}
+	for _, node := range nodes {
+		if counts[node] < 1500 || counts[node] > 3500 {
+			t.Errorf("uneven distribution: %v", counts)
+			break
+		}
+	}
+
+	// adding a node only moves keys to the new node, about 1/5 of them
+	after := newHashRing(append(nodes, "10.0.0.5:8125"))
+	moved := 0
+	for _, k := range keys {
+		n1, _ := before.get(k)
+		n2, _ := after.get(k)
+		if n1 != n2 {
+			moved++
+			if "10.0.0.5:8125" != n2 {
+				t.Fatalf("key %s moved from %s to %s", k, n1, n2)
+			}
+		}
+	}
+	if moved < 1000 || moved > 3000 {
+		t.Errorf("wrong number of keys moved to the new node: %d", moved)
+	}
+
+	// removing a node only moves the keys of that node
+	after = newHashRing(nodes[1:])
+	for _, k := range keys {
+		n1, _ := before.get(k)
+		n2, _ := after.get(k)
+		if n1 != n2 && nodes[0] != n1 {
+			t.Fatalf("key %s moved from %s to %s", k, n1, n2)
+		}
+	}
+
+	if _, ok := newHashRing(nil).get("key"); ok {
+		t.Error("an empty ring should not return any node")
+	}
+}
+
+func TestShardedClient(t *testing.T) {
+	servers := make(map[string]*statsdtest.Server)
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		srv, err := statsdtest.NewServer()
+		if nil != err {
+			t.Fatal(err)
+		}
+		defer srv.Close()
+		servers[srv.Addr] = srv
+		addrs = append(addrs, srv.Addr)
+	}
+
+	client := NewShardedClient(addrs, "test.")
+	if err := client.CreateSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	events := make(map[string]event.Event)
+	for i := 0; i < 30; i++ {
+		stat := "metric" + strconv.Itoa(i)
+		client.Incr(stat, 1, "env:prod")
+		events[stat] = &event.Gauge{Name: stat, Value: int64(i)}
+	}
+	if err := client.SendEvents(events); nil != err {
+		t.Fatal(err)
+	}
+
+	// every metric lands on its server only
+	timeout := time.After(2 * time.Second)
+	for i := 0; i < 30; i++ {
+		stat := "metric" + strconv.Itoa(i)
+		addr := client.ServerFor(stat)
+		for len(servers[addr].Find("test."+stat, "g")) == 0 {
+			select {
+			case <-timeout:
+				t.Fatalf("metric %s not received by %s", stat, addr)
+			case <-time.After(time.Millisecond):
+			}
+		}
+		for other, srv := range servers {
+			if other == addr {
+				srv.AssertCounter(t, "test."+stat, 1, "env:prod")
+				srv.AssertGauge(t, "test."+stat, float64(i))
+			} else {
+				srv.AssertNotReceived(t, "test."+stat, "")
+			}
+		}
+	}
+
+	// remove all the servers
+	for _, addr := range addrs {
+		if err := client.RemoveServer(addr); nil != err {
+			t.Error(err)
+		}
+	}
+	if err := client.Incr("metric", 1); ErrNoServers != err {
+		t.Errorf("expected ErrNoServers, got %v", err)
+	}
+	if err := client.AddServer(addrs[0]); nil != err {
+		t.Fatal(err)
+	}
+	if expected := addrs[:1]; !reflect.DeepEqual(expected, client.Servers()) {
+		t.Errorf("wrong servers: Expected: %v, Actual: %v", expected, client.Servers())
+	}
+	if err := client.Incr("metric", 1); nil != err {
+		t.Error(err)
+	}
+}
+
+func TestShardedClientAddServerConcurrently(t *testing.T) {
+	client := NewShardedClient([]string{"127.0.0.1:8125"}, "test.")
+	if err := client.CreateSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.AddServer("127.0.0.1:8126"); nil != err {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if expected := []string{"127.0.0.1:8125", "127.0.0.1:8126"}; !reflect.DeepEqual(expected, client.Servers()) {
+		t.Errorf("wrong servers: Expected: %v, Actual: %v", expected, client.Servers())
+	}
+	if err := client.Incr("metric", 1); nil != err {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,275 @@
+package statsd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quipo/statsd/event"
+	"github.com/quipo/statsd/parser"
+)
+
+// spoolExt is the extension of the spooled files (the temporary files have a different one)
+const spoolExt = ".statsd"
+
+// Spool persists the events a StatsdBuffer failed to flush to files in a directory,
+// in the StatsD line protocol (without the prefix), instead of keeping them in memory
+// until the server is reachable again. The spooled flushes are sent in order, before
+// the new events, once SendEvents succeeds again.
+// The files left by a previous process in the same directory are sent too.
+type Spool struct {
+	dropped  uint64 // accessed atomically, first for 64-bit alignment
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+
+	mu      sync.Mutex
+	seq     uint64
+	pending int // number of spooled files
+}
+
+// NewSpool creates a spool in a directory (created if needed). The oldest files are
+// discarded when they take more than maxBytes, or when they're older than maxAge
+// (0 for no limit).
+func NewSpool(dir string, maxBytes int64, maxAge time.Duration) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0755); nil != err {
+		return nil, fmt.Errorf("statsd: cannot create the spool directory: %s", err)
+	}
+	s := &Spool{dir: dir, maxBytes: maxBytes, maxAge: maxAge}
+	files, err := s.files()
+	if nil != err {
+		return nil, err
+	}
+	s.pending = len(files)
+	return s, nil
+}
+
+// Dir returns the directory of the spooled files
+func (s *Spool) Dir() string {
+	return s.dir
+}
+
+// Len returns the number of spooled flushes waiting to be sent
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending
+}
+
+// Dropped returns the number of spooled flushes discarded because of maxBytes or maxAge
+func (s *Spool) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// files returns the spooled files, oldest first
+func (s *Spool) files() ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(s.dir) // sorted by name, i.e. by time
+	if nil != err {
+		return nil, err
+	}
+	files := infos[:0]
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), spoolExt) {
+			files = append(files, info)
+		}
+	}
+	return files, nil
+}
+
+// write spools the events to a new file
+func (s *Spool) write(events map[string]event.Event) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	for _, e := range events {
+		// keep the placeholders in the names, to be replaced by the client when sending them
+		*buf = appendEvent(*buf, e, "", nil)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	name := fmt.Sprintf("%020d-%06d", time.Now().UnixNano(), s.seq%1000000)
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := ioutil.WriteFile(tmp, *buf, 0644); nil != err {
+		os.Remove(tmp)
+		return err
+	}
+	// rename, so that a partially written file is never sent
+	if err := os.Rename(tmp, filepath.Join(s.dir, name+spoolExt)); nil != err {
+		os.Remove(tmp)
+		return err
+	}
+	s.pending++
+	return s.trim()
+}
+
+// trim discards the oldest files beyond maxBytes or maxAge. Must be called with the lock held.
+func (s *Spool) trim() error {
+	files, err := s.files()
+	if nil != err {
+		return err
+	}
+	var size int64
+	for _, f := range files {
+		size += f.Size()
+	}
+	for _, f := range files {
+		if (s.maxBytes <= 0 || size <= s.maxBytes) && !s.expired(f) {
+			break
+		}
+		if err = s.remove(f); nil != err {
+			return err
+		}
+		size -= f.Size()
+		atomic.AddUint64(&s.dropped, 1)
+	}
+	return nil
+}
+
+func (s *Spool) expired(f os.FileInfo) bool {
+	return s.maxAge > 0 && time.Since(f.ModTime()) > s.maxAge
+}
+
+// remove a spooled file. Must be called with the lock held.
+func (s *Spool) remove(f os.FileInfo) error {
+	if err := os.Remove(filepath.Join(s.dir, f.Name())); nil != err && !os.IsNotExist(err) {
+		return err
+	}
+	s.pending--
+	return nil
+}
+
+// replay sends the spooled flushes in order, one event per line, removing each file once sent.
+// It stops at the first error, keeping the files not sent yet.
+func (s *Spool) replay(send func(map[string]event.Event) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if 0 == s.pending {
+		return nil
+	}
+	files, err := s.files()
+	if nil != err {
+		return err
+	}
+	s.pending = len(files)
+	for _, f := range files {
+		if s.expired(f) {
+			if err = s.remove(f); nil != err {
+				return err
+			}
+			atomic.AddUint64(&s.dropped, 1)
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(s.dir, f.Name()))
+		if nil != err {
+			return err
+		}
+		events := parseSpooled(string(data))
+		if err = send(events); nil != err {
+			return err
+		}
+		if err = s.remove(f); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseSpooled returns the events of the lines of a spooled flush, skipping the malformed ones
+// (e.g. in a file corrupted by a crash)
+func parseSpooled(data string) map[string]event.Event {
+	lines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	events := make(map[string]event.Event, len(lines))
+	for i, line := range lines {
+		if e, err := newSpooledEvent(line); nil == err {
+			events[strconv.Itoa(i)] = e
+		}
+	}
+	return events
+}
+
+// eventSpooled is the type identifier of the spooled events
+const eventSpooled = -1
+
+// spooledEvent is a line of a spooled flush. The line is parsed to get the name and the tags
+// of its metric, so that it's routed, filtered and renamed like the other events, but its value
+// is sent as it was spooled: e.g. the ".avg" line of a Timing parsed back into a Timing
+// would be sent as new ".avg.count", ".avg.avg"... lines.
+type spooledEvent struct {
+	name  string
+	value string // value, type and sample rate, e.g. "1|c"
+	tags  []string
+}
+
+func newSpooledEvent(line string) (*spooledEvent, error) {
+	e, err := parser.ParseLine(line)
+	if nil != err {
+		return nil, err
+	}
+	value := line[len(e.Key())+1:]
+	if i := strings.Index(value, "|#"); i >= 0 {
+		// the tags are always last
+		value = value[:i]
+	}
+	return &spooledEvent{name: e.Key(), value: value, tags: e.GetTags()}, nil
+}
+
+// Update returns an error, the spooled lines are not aggregated
+func (e *spooledEvent) Update(e2 event.Event) error {
+	return fmt.Errorf("statsd event type conflict: %s vs %s ", e.String(), e2.String())
+}
+
+// Payload returns the spooled value, type and sample rate
+func (e spooledEvent) Payload() interface{} {
+	return e.value
+}
+
+// Stats returns the spooled line, with the current name and tags
+func (e spooledEvent) Stats() []string {
+	line := e.name + ":" + e.value
+	if len(e.tags) > 0 {
+		line += "|#" + strings.Join(e.tags, ",")
+	}
+	return []string{line}
+}
+
+// Key returns the name of the metric
+func (e spooledEvent) Key() string {
+	return e.name
+}
+
+// SetKey sets the name of the metric
+func (e *spooledEvent) SetKey(key string) {
+	e.name = key
+}
+
+// GetTags returns the tags of the metric
+func (e spooledEvent) GetTags() []string {
+	return e.tags
+}
+
+// SetTags sets the tags of the metric
+func (e *spooledEvent) SetTags(tags []string) {
+	e.tags = tags
+}
+
+// Type returns an integer identifier for this type of event
+func (e spooledEvent) Type() int {
+	return eventSpooled
+}
+
+// TypeString returns a name for this type of event
+func (e spooledEvent) TypeString() string {
+	return "Spooled"
+}
+
+// String returns a debug-friendly representation of this event
+func (e spooledEvent) String() string {
+	return fmt.Sprintf("{Type: %s, Key: %s, Value: %s, Tags: %v}", e.TypeString(), e.name, e.value, e.tags)
+}
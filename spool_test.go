@@ -0,0 +1,206 @@
+package statsd
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quipo/statsd/event"
+)
+
+// flakyClient fails to send the events while down, and records their lines otherwise
+type flakyClient struct {
+	NoopClient
+	mu    sync.Mutex
+	down  bool
+	lines []string
+}
+
+func (c *flakyClient) setDown(down bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.down = down
+}
+
+func (c *flakyClient) SendEvents(events map[string]event.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.down {
+		return errors.New("server unreachable")
+	}
+	for _, e := range events {
+		c.lines = append(c.lines, e.Stats()...)
+	}
+	return nil
+}
+
+func newTestSpool(t *testing.T, maxBytes int64, maxAge time.Duration) *Spool {
+	dir, err := ioutil.TempDir("", "statsd")
+	if nil != err {
+		t.Fatal(err)
+	}
+	spool, err := NewSpool(dir, maxBytes, maxAge)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return spool
+}
+
+func waitSpooled(t *testing.T, spool *Spool, n int) {
+	timeout := time.After(2 * time.Second)
+	for spool.Len() < n {
+		select {
+		case <-timeout:
+			t.Fatalf("timed out waiting for %d spooled flushes", n)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestBufferedSpool(t *testing.T) {
+	spool := newTestSpool(t, 0, 0)
+	defer os.RemoveAll(spool.Dir())
+
+	client := &flakyClient{down: true}
+	buffered, err := NewStatsdBufferWithOptions(5*time.Millisecond, client,
+		WithSpool(spool),
+		WithLogger(log.New(ioutil.Discard, "", 0)),
+		WithVerbose(false),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	buffered.Incr("a", 1)
+	waitSpooled(t, spool, 1)
+	buffered.Gauge("%HOST%.b", 2, "env:prod")
+	waitSpooled(t, spool, 2)
+
+	// the spooled flushes are sent first, in order
+	client.setDown(false)
+	buffered.Incr("c", 3)
+	if err = buffered.Close(); nil != err {
+		t.Fatal(err)
+	}
+	expected := []string{"a:1|c", "%HOST%.b:2|g|#env:prod", "c:3|c"}
+	if !reflect.DeepEqual(expected, client.lines) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, client.lines, client.lines)
+	}
+	if 0 != spool.Len() {
+		t.Errorf("the spool should be empty, %d flushes left", spool.Len())
+	}
+	if files, _ := spool.files(); 0 != len(files) {
+		t.Errorf("the spooled files should be removed: %v", files)
+	}
+}
+
+func TestSpoolLimits(t *testing.T) {
+	// each flush takes 12 bytes: only one fits
+	spool := newTestSpool(t, 20, 0)
+	defer os.RemoveAll(spool.Dir())
+	for _, name := range []string{"metric1", "metric2", "metric3"} {
+		if err := spool.write(map[string]event.Event{name: &event.Increment{Name: name, Value: 1}}); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if 1 != spool.Len() || 2 != spool.Dropped() {
+		t.Errorf("wrong spooled flushes: %d, dropped: %d", spool.Len(), spool.Dropped())
+	}
+
+	// the files left by a previous spool are sent too
+	reopened, err := NewSpool(spool.Dir(), 20, 0)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 1 != reopened.Len() {
+		t.Errorf("wrong spooled flushes: %d", reopened.Len())
+	}
+	client := &flakyClient{}
+	if err = reopened.replay(client.SendEvents); nil != err {
+		t.Fatal(err)
+	}
+	if expected := []string{"metric3:1|c"}; !reflect.DeepEqual(expected, client.lines) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, client.lines, client.lines)
+	}
+
+	// the flushes too old are discarded
+	old := newTestSpool(t, 0, time.Millisecond)
+	defer os.RemoveAll(old.Dir())
+	if err = old.write(map[string]event.Event{"a": &event.Increment{Name: "a", Value: 1}}); nil != err {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	client = &flakyClient{}
+	if err = old.replay(client.SendEvents); nil != err {
+		t.Fatal(err)
+	}
+	if 0 != len(client.lines) || 1 != old.Dropped() || 0 != old.Len() {
+		t.Errorf("the old flush should be discarded: %v, dropped: %d", client.lines, old.Dropped())
+	}
+}
+
+func TestSpoolTemplates(t *testing.T) {
+	spool := newTestSpool(t, 0, 0)
+	defer os.RemoveAll(spool.Dir())
+
+	// the spooled lines keep their placeholders, replaced by the client when replayed
+	if err := spool.write(map[string]event.Event{"r": &event.Increment{Name: "%HOST%.req", Value: 1}}); nil != err {
+		t.Fatal(err)
+	}
+	client, err := NewStatsdClientWithOptions("127.0.0.1:8125", "p.",
+		WithHostname("h1"),
+		WithPayloadSize(1432),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	mock := &MockNetConn{}
+	client.conn = mock
+
+	if err = spool.replay(client.SendEvents); nil != err {
+		t.Fatal(err)
+	}
+	if expected := "p.h1.req:1|c"; expected != strings.TrimSpace(mock.buf.String()) {
+		t.Errorf("unexpected metric: Expected: %q, Actual: %q", expected, mock.buf.String())
+	}
+}
+
+func TestSpoolReplayPerMetric(t *testing.T) {
+	spool := newTestSpool(t, 0, 0)
+	defer os.RemoveAll(spool.Dir())
+
+	err := spool.write(map[string]event.Event{
+		"a":     &event.Increment{Name: "a", Value: 1},
+		"debug": &event.Gauge{Name: "debug.x", Value: 2},
+		"t":     &event.Timing{Name: "t", Value: 4, Count: 1, Min: 4, Max: 4, Tags: []string{"env:prod"}},
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// the replayed lines are events named after their metric, filtered like the others
+	client := &flakyClient{}
+	filtered, err := NewFilterClient(client,
+		Rule{Match: "debug.**", Action: RuleDrop},
+		Rule{Match: "t.*", Action: RuleRename, Replacement: "timer.$1"},
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err = spool.replay(filtered.SendEvents); nil != err {
+		t.Fatal(err)
+	}
+	sort.Strings(client.lines)
+	expected := []string{"a:1|c", "timer.avg:4|ms|#env:prod", "timer.count:1|c|#env:prod", "timer.max:4|ms|#env:prod", "timer.min:4|ms|#env:prod"}
+	if !reflect.DeepEqual(expected, client.lines) {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, client.lines, client.lines)
+	}
+}
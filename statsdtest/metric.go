@@ -0,0 +1,58 @@
+package statsdtest
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/quipo/statsd/parser"
+)
+
+// Metric is a single StatsD metric as received by the Server
+type Metric parser.Fields
+
+// Float returns the numeric value of the metric (0 for non-numeric values)
+func (m Metric) Float() float64 {
+	v, _ := strconv.ParseFloat(m.Value, 64)
+	return v
+}
+
+// IsDelta returns true if the value has a leading sign, i.e. it's a change
+// to the previous value of a gauge rather than a new value
+func (m Metric) IsDelta() bool {
+	return strings.HasPrefix(m.Value, "+") || strings.HasPrefix(m.Value, "-")
+}
+
+// HasTags returns true if the metric has all the given tags
+func (m Metric) HasTags(tags ...string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, t := range m.Tags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the metric as it travels over the wire
+func (m Metric) String() string {
+	s := m.Name + ":" + m.Value + "|" + m.Type
+	if m.SampleRate != 1 {
+		s += "|@" + strconv.FormatFloat(m.SampleRate, 'f', -1, 64)
+	}
+	if len(m.Tags) > 0 {
+		s += "|#" + strings.Join(m.Tags, ",")
+	}
+	return s
+}
+
+// parseLine parses a single "name:value|type|@rate|#tags" line
+func parseLine(line string) (Metric, error) {
+	f, err := parser.SplitLine(line)
+	return Metric(f), err
+}
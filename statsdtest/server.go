@@ -0,0 +1,351 @@
+// Package statsdtest provides an in-process StatsD server to test
+// the metrics sent over the wire by the statsd clients.
+//
+// Sample usage:
+//
+//	srv, err := statsdtest.NewServer()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer srv.Close()
+//
+//	client := statsd.NewStatsdClient(srv.Addr, "myproject.")
+//	client.CreateSocket()
+//	client.Incr("requests", 1)
+//
+//	if err := srv.WaitFor(1); err != nil {
+//		t.Fatal(err)
+//	}
+//	srv.AssertCounter(t, "myproject.requests", 1)
+package statsdtest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is the default time WaitFor waits for the metrics to arrive
+var DefaultTimeout = 2 * time.Second
+
+// TestingT is the subset of testing.TB used by the assertions
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Server is an in-process StatsD server, listening on a free local port,
+// recording all the metrics it receives
+type Server struct {
+	// Addr is the address the server listens on, to pass to statsd.NewStatsdClient
+	Addr string
+	// Timeout is the maximum time WaitFor waits for the metrics to arrive
+	Timeout time.Duration
+
+	udpConn  *net.UDPConn
+	listener net.Listener
+	conns    map[net.Conn]struct{} // open TCP connections
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	metrics []Metric
+	errors  []error
+	notify  chan struct{}
+	closed  bool
+}
+
+// NewServer starts a UDP StatsD server on a free local port
+func NewServer() (*Server, error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return nil, err
+	}
+	s := newServer(conn.LocalAddr().String())
+	s.udpConn = conn
+	s.wg.Add(1)
+	go s.serveUDP()
+	return s, nil
+}
+
+// NewTCPServer starts a TCP StatsD server on a free local port
+func NewTCPServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := newServer(ln.Addr().String())
+	s.listener = ln
+	s.wg.Add(1)
+	go s.serveTCP()
+	return s, nil
+}
+
+func newServer(addr string) *Server {
+	return &Server{
+		Addr:    addr,
+		Timeout: DefaultTimeout,
+		conns:   make(map[net.Conn]struct{}),
+		notify:  make(chan struct{}),
+	}
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	var err error
+	if nil != s.udpConn {
+		err = s.udpConn.Close()
+	}
+	if nil != s.listener {
+		err = s.listener.Close()
+	}
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveUDP() {
+	defer s.wg.Done()
+	buf := make([]byte, 65535)
+	for {
+		n, err := s.udpConn.Read(buf)
+		if err != nil {
+			return // closed
+		}
+		s.receive(string(buf[:n]))
+	}
+}
+
+func (s *Server) serveTCP() {
+	defer s.wg.Done()
+	var conns sync.WaitGroup
+	defer conns.Wait()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return // closed
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		conns.Add(1)
+		go func(conn net.Conn) {
+			defer conns.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				s.receive(scanner.Text())
+			}
+		}(conn)
+	}
+}
+
+// receive parses and records the metrics in a packet (or line)
+func (s *Server) receive(packet string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, line := range strings.Split(packet, "\n") {
+		if line = strings.TrimSpace(line); "" == line {
+			continue
+		}
+		m, err := parseLine(line)
+		if err != nil {
+			s.errors = append(s.errors, err)
+			continue
+		}
+		s.metrics = append(s.metrics, m)
+	}
+	// wake up all the goroutines waiting for new metrics
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// WaitFor waits until at least n metrics have been received in total,
+// or returns an error after Timeout
+func (s *Server) WaitFor(n int) error {
+	timeout := time.After(s.Timeout)
+	for {
+		s.mu.Lock()
+		received, notify := len(s.metrics), s.notify
+		s.mu.Unlock()
+		if received >= n {
+			return nil
+		}
+		select {
+		case <-notify:
+		case <-timeout:
+			return fmt.Errorf("statsdtest: timed out waiting for %d metrics, received %d", n, received)
+		}
+	}
+}
+
+// Metrics returns all the metrics received so far
+func (s *Server) Metrics() []Metric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]Metric, len(s.metrics))
+	copy(ret, s.metrics)
+	return ret
+}
+
+// Errors returns the errors parsing the lines received so far
+func (s *Server) Errors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]error, len(s.errors))
+	copy(ret, s.errors)
+	return ret
+}
+
+// Reset forgets all the metrics and errors received so far
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = nil
+	s.errors = nil
+}
+
+// Find returns the metrics received with the given name and type
+// (any type if empty), having all the given tags
+func (s *Server) Find(name string, typ string, tags ...string) []Metric {
+	var ret []Metric
+	for _, m := range s.Metrics() {
+		if m.Name == name && ("" == typ || m.Type == typ) && m.HasTags(tags...) {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+// Counter returns the total of a counter, scaled by the sample rate
+// as a StatsD server would do
+func (s *Server) Counter(name string, tags ...string) int64 {
+	var total float64
+	for _, m := range s.Find(name, "c", tags...) {
+		rate := m.SampleRate
+		if rate <= 0 {
+			rate = 1 // not a valid sample rate: count the value once
+		}
+		total += m.Float() / rate
+	}
+	return int64(total)
+}
+
+// Gauge returns the current value of a gauge, applying the deltas
+// (values with a leading '+' or '-') to the previous value
+func (s *Server) Gauge(name string, tags ...string) float64 {
+	var value float64
+	for _, m := range s.Find(name, "g", tags...) {
+		if m.IsDelta() {
+			value += m.Float()
+		} else {
+			value = m.Float()
+		}
+	}
+	return value
+}
+
+// Timings returns all the values of a timer, in the order they were received
+func (s *Server) Timings(name string, tags ...string) []float64 {
+	return s.values(name, "ms", tags...)
+}
+
+// Histogram returns all the values of a histogram, in the order they were received
+func (s *Server) Histogram(name string, tags ...string) []float64 {
+	return s.values(name, "h", tags...)
+}
+
+// Distribution returns all the values of a distribution, in the order they were received
+func (s *Server) Distribution(name string, tags ...string) []float64 {
+	return s.values(name, "d", tags...)
+}
+
+// Set returns the unique values of a set, in the order they were first received
+func (s *Server) Set(name string, tags ...string) []string {
+	var ret []string
+	seen := make(map[string]bool)
+	for _, m := range s.Find(name, "s", tags...) {
+		if !seen[m.Value] {
+			seen[m.Value] = true
+			ret = append(ret, m.Value)
+		}
+	}
+	return ret
+}
+
+func (s *Server) values(name string, typ string, tags ...string) []float64 {
+	var ret []float64
+	for _, m := range s.Find(name, typ, tags...) {
+		ret = append(ret, m.Float())
+	}
+	return ret
+}
+
+// AssertCounter fails the test if the total of a counter is not the expected one
+func (s *Server) AssertCounter(t TestingT, name string, expected int64, tags ...string) bool {
+	t.Helper()
+	if actual := s.Counter(name, tags...); actual != expected {
+		t.Errorf("statsdtest: wrong value for counter %s: expected %d, actual %d", name, expected, actual)
+		return false
+	}
+	return true
+}
+
+// AssertGauge fails the test if the current value of a gauge is not the expected one
+func (s *Server) AssertGauge(t TestingT, name string, expected float64, tags ...string) bool {
+	t.Helper()
+	if len(s.Find(name, "g", tags...)) == 0 {
+		t.Errorf("statsdtest: gauge %s not received", name)
+		return false
+	}
+	if actual := s.Gauge(name, tags...); actual != expected {
+		t.Errorf("statsdtest: wrong value for gauge %s: expected %g, actual %g", name, expected, actual)
+		return false
+	}
+	return true
+}
+
+// AssertReceived fails the test if no metric with the given name and type
+// (any type if empty) was received
+func (s *Server) AssertReceived(t TestingT, name string, typ string, tags ...string) bool {
+	t.Helper()
+	if len(s.Find(name, typ, tags...)) == 0 {
+		t.Errorf("statsdtest: metric %s not received", name)
+		return false
+	}
+	return true
+}
+
+// AssertNotReceived fails the test if any metric with the given name and type
+// (any type if empty) was received
+func (s *Server) AssertNotReceived(t TestingT, name string, typ string, tags ...string) bool {
+	t.Helper()
+	if found := s.Find(name, typ, tags...); len(found) > 0 {
+		t.Errorf("statsdtest: unexpected metric %s received: %v", name, found)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package statsdtest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/quipo/statsd"
+)
+
+func TestServerUDP(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	client := statsd.NewStatsdClient(srv.Addr, "myproject.")
+	if err = client.CreateSocket(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	client.Incr("requests", 2, "env:prod")
+	client.Incr("requests", 3, "env:dev")
+	client.Gauge("queue", 5)
+	client.Gauge("queue", -3) // sent as 0 then -3
+	client.GaugeDelta("queue", 1)
+	client.Timing("latency", 12)
+	client.Set("users", "alice")
+	client.Set("users", "alice")
+
+	if err = srv.WaitFor(9); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.AssertCounter(t, "myproject.requests", 5)
+	srv.AssertCounter(t, "myproject.requests", 2, "env:prod")
+	srv.AssertGauge(t, "myproject.queue", -2)
+	srv.AssertReceived(t, "myproject.latency", "ms")
+	srv.AssertNotReceived(t, "myproject.latency", "c")
+	if expected, actual := []float64{12}, srv.Timings("myproject.latency"); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("wrong timings: Expected: %v, Actual: %v", expected, actual)
+	}
+	if expected, actual := []string{"alice"}, srv.Set("myproject.users"); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("wrong set: Expected: %v, Actual: %v", expected, actual)
+	}
+	if errs := srv.Errors(); len(errs) > 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+func TestServerTCPBuffered(t *testing.T) {
+	srv, err := NewTCPServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	client := statsd.NewStatsdClient(srv.Addr, "myproject.")
+	buffered := statsd.NewStatsdBuffer(time.Hour, client)
+	buffered.Verbose = false
+	if err = buffered.CreateTCPSocket(); err != nil {
+		t.Fatal(err)
+	}
+
+	buffered.Incr("requests", 1)
+	buffered.Incr("requests", 1)
+	buffered.FGauge("load", 0.5)
+	if err = buffered.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = srv.WaitFor(2); err != nil {
+		t.Fatal(err)
+	}
+	srv.AssertCounter(t, "myproject.requests", 2)
+	srv.AssertGauge(t, "myproject.load", 0.5)
+	if n := len(srv.Metrics()); n != 2 {
+		t.Errorf("the buffered client should have aggregated the metrics, received %d", n)
+	}
+
+	srv.Reset()
+	if n := len(srv.Metrics()); n != 0 {
+		t.Errorf("expected no metrics after a reset, found %d", n)
+	}
+}
+
+func TestWaitForTimeout(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.Timeout = 10 * time.Millisecond
+
+	if err = srv.WaitFor(1); err == nil {
+		t.Error("expected a timeout error")
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	tt := []struct {
+		line     string
+		expected Metric
+	}{
+		{"a:b:c:5|c", Metric{Name: "a:b:c", Value: "5", Type: "c", SampleRate: 1}},
+		{"x:+2.5|g|@0.5", Metric{Name: "x", Value: "+2.5", Type: "g", SampleRate: 0.5}},
+		{"x:1|ms|@0.1|#env:prod,a:b", Metric{Name: "x", Value: "1", Type: "ms", SampleRate: 0.1, Tags: []string{"env:prod", "a:b"}}},
+		{"users:urn:user:1|s", Metric{Name: "users", Value: "urn:user:1", Type: "s", SampleRate: 1}},
+	}
+	for _, tc := range tt {
+		actual, err := parseLine(tc.line)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !reflect.DeepEqual(tc.expected, actual) {
+			t.Errorf("wrong metric parsed: Expected: %v, Actual: %v", tc.expected, actual)
+		}
+		if actual.String() != tc.line {
+			t.Errorf("wrong metric string: Expected: %s, Actual: %s", tc.line, actual.String())
+		}
+	}
+
+	for _, line := range []string{"x", ":1|c", "x:1", "x:|c", "x:1|c|@y", "x:1|c|@0", "x:1|c|z"} {
+		if _, err := parseLine(line); err == nil {
+			t.Errorf("expected an error parsing %q", line)
+		}
+	}
+}
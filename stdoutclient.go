@@ -20,8 +20,8 @@ type StdoutClient struct {
 // NewStdoutClient - Factory
 func NewStdoutClient(filename string, prefix string) *StdoutClient {
 	var err error
-	// allow %HOST% in the prefix string
-	prefix = strings.Replace(prefix, "%HOST%", Hostname, 1)
+	// allow %HOST% and the other placeholders in the prefix string
+	prefix = expandTemplate(prefix, Hostname)
 	var fh *os.File
 	if filename == "" {
 		fh = os.Stdout
@@ -60,31 +60,31 @@ func (s *StdoutClient) Close() error {
 }
 
 // Incr - Increment a counter metric. Often used to note a particular event
-func (s *StdoutClient) Incr(stat string, count int64) error {
+func (s *StdoutClient) Incr(stat string, count int64, tags ...string) error {
 	if 0 != count {
-		return s.send(stat, "%d|c", count)
+		return s.send(stat, "%d|c", count, tags)
 	}
 	return nil
 }
 
 // Decr - Decrement a counter metric. Often used to note a particular event
-func (s *StdoutClient) Decr(stat string, count int64) error {
+func (s *StdoutClient) Decr(stat string, count int64, tags ...string) error {
 	if 0 != count {
-		return s.send(stat, "%d|c", -count)
+		return s.send(stat, "%d|c", -count, tags)
 	}
 	return nil
 }
 
 // Timing - Track a duration event
 // the time delta must be given in milliseconds
-func (s *StdoutClient) Timing(stat string, delta int64) error {
-	return s.send(stat, "%d|ms", delta)
+func (s *StdoutClient) Timing(stat string, delta int64, tags ...string) error {
+	return s.send(stat, "%d|ms", delta, tags)
 }
 
 // PrecisionTiming - Track a duration event
 // the time delta has to be a duration
-func (s *StdoutClient) PrecisionTiming(stat string, delta time.Duration) error {
-	return s.send(stat, "%.6f|ms", float64(delta)/float64(time.Millisecond))
+func (s *StdoutClient) PrecisionTiming(stat string, delta time.Duration, tags ...string) error {
+	return s.send(stat, "%.6f|ms", float64(delta)/float64(time.Millisecond), tags)
 }
 
 // Gauge - Gauges are a constant data type. They are not subject to averaging,
@@ -93,75 +93,96 @@ func (s *StdoutClient) PrecisionTiming(stat string, delta time.Duration) error {
 // delta to be true, that specifies that the gauge should be updated, not set. Due to the
 // underlying protocol, you can't explicitly set a gauge to a negative number without
 // first setting it to zero.
-func (s *StdoutClient) Gauge(stat string, value int64) error {
+func (s *StdoutClient) Gauge(stat string, value int64, tags ...string) error {
 	if value < 0 {
-		err := s.send(stat, "%d|g", 0)
+		err := s.send(stat, "%d|g", 0, tags)
 		if nil != err {
 			return err
 		}
-		return s.send(stat, "%d|g", value)
+		return s.send(stat, "%d|g", value, tags)
 	}
-	return s.send(stat, "%d|g", value)
+	return s.send(stat, "%d|g", value, tags)
 }
 
 // GaugeDelta -- Send a change for a gauge
-func (s *StdoutClient) GaugeDelta(stat string, value int64) error {
+func (s *StdoutClient) GaugeDelta(stat string, value int64, tags ...string) error {
 	// Gauge Deltas are always sent with a leading '+' or '-'. The '-' takes care of itself but the '+' must added by hand
 	if value < 0 {
-		return s.send(stat, "%d|g", value)
+		return s.send(stat, "%d|g", value, tags)
 	}
-	return s.send(stat, "+%d|g", value)
+	return s.send(stat, "+%d|g", value, tags)
 }
 
 // FGauge -- Send a floating point value for a gauge
-func (s *StdoutClient) FGauge(stat string, value float64) error {
+func (s *StdoutClient) FGauge(stat string, value float64, tags ...string) error {
 	if value < 0 {
-		err := s.send(stat, "%d|g", 0)
+		err := s.send(stat, "%d|g", 0, tags)
 		if nil != err {
 			return err
 		}
-		return s.send(stat, "%g|g", value)
+		return s.send(stat, "%g|g", value, tags)
 	}
-	return s.send(stat, "%g|g", value)
+	return s.send(stat, "%g|g", value, tags)
 }
 
 // FGaugeDelta -- Send a floating point change for a gauge
-func (s *StdoutClient) FGaugeDelta(stat string, value float64) error {
+func (s *StdoutClient) FGaugeDelta(stat string, value float64, tags ...string) error {
 	if value < 0 {
-		return s.send(stat, "%g|g", value)
+		return s.send(stat, "%g|g", value, tags)
 	}
-	return s.send(stat, "+%g|g", value)
+	return s.send(stat, "+%g|g", value, tags)
 }
 
 // Absolute - Send absolute-valued metric (not averaged/aggregated)
-func (s *StdoutClient) Absolute(stat string, value int64) error {
-	return s.send(stat, "%d|a", value)
+func (s *StdoutClient) Absolute(stat string, value int64, tags ...string) error {
+	return s.send(stat, "%d|a", value, tags)
 }
 
 // FAbsolute - Send absolute-valued floating point metric (not averaged/aggregated)
-func (s *StdoutClient) FAbsolute(stat string, value float64) error {
-	return s.send(stat, "%g|a", value)
+func (s *StdoutClient) FAbsolute(stat string, value float64, tags ...string) error {
+	return s.send(stat, "%g|a", value, tags)
 }
 
 // Total - Send a metric that is continously increasing, e.g. read operations since boot
-func (s *StdoutClient) Total(stat string, value int64) error {
-	return s.send(stat, "%d|t", value)
+func (s *StdoutClient) Total(stat string, value int64, tags ...string) error {
+	return s.send(stat, "%d|t", value, tags)
+}
+
+// Set - Count the unique values seen for a metric (e.g. unique users per interval)
+func (s *StdoutClient) Set(stat string, value string, tags ...string) error {
+	return s.send(stat, "%s|s", value, tags)
+}
+
+// Histogram - Send a value to be aggregated into a statistical distribution by the server (per host)
+func (s *StdoutClient) Histogram(stat string, value float64, tags ...string) error {
+	return s.send(stat, "%g|h", value, tags)
+}
+
+// Distribution - Send a value to be aggregated into a statistical distribution
+// by the server (globally, across all hosts)
+func (s *StdoutClient) Distribution(stat string, value float64, tags ...string) error {
+	return s.send(stat, "%g|d", value, tags)
 }
 
 // write a UDP packet with the statsd event
-func (s *StdoutClient) send(stat string, format string, value interface{}) error {
-	stat = strings.Replace(stat, "%HOST%", Hostname, 1)
+func (s *StdoutClient) send(stat string, format string, value interface{}, tags []string) error {
+	stat = expandGlobal(stat)
+	metricString := s.prefix + stat + ":" + fmt.Sprintf(format, value)
+	if len(tags) > 0 {
+		metricString += "|#" + strings.Join(tags, ",")
+	}
 	// if sending tcp append a newline
-	format = fmt.Sprintf("%s%s:%s\n", s.prefix, stat, format)
-	_, err := fmt.Fprintf(s.FD, format, value)
+	_, err := fmt.Fprint(s.FD, metricString+"\n")
 	return err
 }
 
 // SendEvent - Sends stats from an event object
 func (s *StdoutClient) SendEvent(e event.Event) error {
+	key := e.Key()
+	name := expandGlobal(key)
 	for _, stat := range e.Stats() {
-		//fmt.Printf("SENDING EVENT %s%s\n", s.prefix, strings.Replace(stat, "%HOST%", Hostname, 1))
-		_, err := fmt.Fprintf(s.FD, "%s%s", s.prefix, strings.Replace(stat, "%HOST%", Hostname, 1))
+		//fmt.Printf("SENDING EVENT %s%s\n", s.prefix, expandLine(stat, key, name))
+		_, err := fmt.Fprintf(s.FD, "%s%s", s.prefix, expandLine(stat, key, name))
 		if nil != err {
 			return err
 		}
@@ -170,39 +191,10 @@ func (s *StdoutClient) SendEvent(e event.Event) error {
 }
 
 // SendEvents - Sends stats from all the event objects.
-// Tries to bundle many together into one fmt.Fprintf based on UDPPayloadSize.
+// Tries to bundle many together into one write based on UDPPayloadSize.
 func (s *StdoutClient) SendEvents(events map[string]event.Event) error {
-	var n int
-	var stats = make([]string, 0)
-
-	for _, e := range events {
-		for _, stat := range e.Stats() {
-
-			stat = fmt.Sprintf("%s%s", s.prefix, strings.Replace(stat, "%HOST%", Hostname, 1))
-			_n := n + len(stat) + 1
-
-			if _n > UDPPayloadSize {
-				// with this last event, the UDP payload would be too big
-				if _, err := fmt.Fprintf(s.FD, strings.Join(stats, "\n")); err != nil {
-					return err
-				}
-				// reset payload after flushing, and add the last event
-				stats = []string{stat}
-				n = len(stat)
-				continue
-			}
-
-			// can fit more into the current payload
-			n = _n
-			stats = append(stats, stat)
-		}
-	}
-
-	if len(stats) != 0 {
-		if _, err := fmt.Fprintf(s.FD, strings.Join(stats, "\n")); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return packEvents(events, s.prefix, expandGlobal, UDPPayloadSize, func(payload []byte) error {
+		_, err := s.FD.Write(payload)
+		return err
+	})
 }
@@ -0,0 +1,181 @@
+package statsd
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quipo/statsd/event"
+)
+
+// DefaultTelemetryNamespace is the namespace of the metrics about the client itself
+const DefaultTelemetryNamespace = "statsd.client"
+
+// DefaultTelemetryInterval is how often a StatsdClient sends the metrics about itself, by default
+var DefaultTelemetryInterval = 10 * time.Second
+
+// ClientStats are the counters of the activity of a StatsdClient since its creation
+type ClientStats struct {
+	PacketsSent  uint64 // successful writes to the socket
+	BytesWritten uint64
+	WriteErrors  uint64
+}
+
+// BufferStats are the counters of the activity of a StatsdBuffer since its creation
+type BufferStats struct {
+	EventsReceived    uint64 // events passed to the metric functions
+	EventsDropped     uint64 // events dropped because of the overflow policy
+	EventsRejected    uint64 // events dropped or folded because of the cardinality limits
+	Flushes           uint64
+	FlushErrors       uint64
+	LastFlushDuration time.Duration
+	LastFlushSize     int64 // number of aggregated events sent by the last flush
+}
+
+// Stats returns the counters of the activity of the client
+func (c *StatsdClient) Stats() ClientStats {
+	return ClientStats{
+		PacketsSent:  atomic.LoadUint64(&c.stats.PacketsSent),
+		BytesWritten: atomic.LoadUint64(&c.stats.BytesWritten),
+		WriteErrors:  atomic.LoadUint64(&c.stats.WriteErrors),
+	}
+}
+
+// Stats returns the counters of the activity of the buffered client
+func (sb *StatsdBuffer) Stats() BufferStats {
+	return BufferStats{
+		EventsReceived:    atomic.LoadUint64(&sb.stats.EventsReceived) + sb.shards.received(),
+		EventsDropped:     sb.Dropped(),
+		EventsRejected:    sb.Rejected(),
+		Flushes:           atomic.LoadUint64(&sb.stats.Flushes),
+		FlushErrors:       atomic.LoadUint64(&sb.stats.FlushErrors),
+		LastFlushDuration: time.Duration(atomic.LoadInt64((*int64)(&sb.stats.LastFlushDuration))),
+		LastFlushSize:     atomic.LoadInt64(&sb.stats.LastFlushSize),
+	}
+}
+
+// received counts an event passed to the metric functions (the shards, if any, count their own)
+func (sb *StatsdBuffer) received() {
+	atomic.AddUint64(&sb.stats.EventsReceived, 1)
+}
+
+// flushed records the outcome of a flush
+func (sb *StatsdBuffer) flushed(start time.Time, size int, err error) {
+	atomic.AddUint64(&sb.stats.Flushes, 1)
+	if nil != err {
+		atomic.AddUint64(&sb.stats.FlushErrors, 1)
+	}
+	atomic.StoreInt64((*int64)(&sb.stats.LastFlushDuration), int64(time.Since(start)))
+	atomic.StoreInt64(&sb.stats.LastFlushSize, int64(size))
+}
+
+// telemetry is the state of the emission of the metrics about the client itself
+type telemetry struct {
+	namespace string
+	mu        sync.Mutex  // guards client, for a StatsdClient sending them in the background
+	buffer    BufferStats // at the previous flush, to send the counters as deltas
+	client    ClientStats
+}
+
+// clientEvents returns the counters of a StatsdClient since the previous ones sent
+func (t *telemetry) clientEvents(stats ClientStats) []event.Event {
+	counter := func(name string, value, previous uint64) event.Event {
+		return &event.Increment{Name: t.namespace + "." + name, Value: int64(value - previous)}
+	}
+	return []event.Event{
+		counter("packets_sent", stats.PacketsSent, t.client.PacketsSent),
+		counter("bytes_written", stats.BytesWritten, t.client.BytesWritten),
+		counter("write_errors", stats.WriteErrors, t.client.WriteErrors),
+	}
+}
+
+// addTelemetry adds the metrics about the buffered client (and its StatsdClient, if any,
+// unless it sends its own) since the previous flush to the pending events, adding them
+// to the ones still pending if the previous flush failed.
+// This function is NOT thread-safe, so it must only be invoked synchronously
+// from within the collector() goroutine
+func (sb *StatsdBuffer) addTelemetry() {
+	t := sb.telemetry
+	if nil == t {
+		return
+	}
+	add := func(e event.Event) {
+		k := "telemetry|" + e.Key()
+		if e2, ok := sb.events[k]; ok && nil == e2.Update(e) {
+			return
+		}
+		sb.events[k] = e
+	}
+	counter := func(name string, value, previous uint64) {
+		add(&event.Increment{Name: t.namespace + "." + name, Value: int64(value - previous)})
+	}
+
+	stats := sb.Stats()
+	counter("events_received", stats.EventsReceived, t.buffer.EventsReceived)
+	counter("events_dropped", stats.EventsDropped, t.buffer.EventsDropped)
+	counter("events_rejected", stats.EventsRejected, t.buffer.EventsRejected)
+	counter("flush_errors", stats.FlushErrors, t.buffer.FlushErrors)
+	add(&event.Gauge{Name: t.namespace + ".flush_size", Value: stats.LastFlushSize})
+	add(&event.FGauge{Name: t.namespace + ".flush_duration_ms", Value: stats.LastFlushDuration.Seconds() * 1000})
+	t.buffer = stats
+
+	if client, ok := sb.statsd.(*StatsdClient); ok && nil == client.telemetry {
+		stats := client.Stats()
+		for _, e := range t.clientEvents(stats) {
+			add(e)
+		}
+		t.client = stats
+	}
+}
+
+// startTelemetry starts sending the metrics about the client at its telemetry interval.
+// Must be called with the lock held.
+func (c *StatsdClient) startTelemetry() {
+	interval := c.telemetryInterval
+	if interval <= 0 {
+		interval = DefaultTelemetryInterval
+	}
+	c.telemetryDone = make(chan struct{})
+	go c.telemetryLoop(interval, c.telemetryDone)
+}
+
+// stopTelemetry stops sending the metrics about the client, if running. Must be called with the lock held.
+func (c *StatsdClient) stopTelemetry() {
+	if nil != c.telemetryDone {
+		close(c.telemetryDone)
+		c.telemetryDone = nil
+	}
+}
+
+func (c *StatsdClient) telemetryLoop(interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.sendTelemetry(); nil != err {
+				handleError(c.OnError, c.Logger, &Error{Kind: ErrorFlush, Err: err}, "Error sending telemetry", err.Error())
+			}
+		}
+	}
+}
+
+// sendTelemetry sends the counters of the client since the previous ones sent:
+// if it fails, they are sent again with the next ones
+func (c *StatsdClient) sendTelemetry() error {
+	t := c.telemetry
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := c.Stats()
+	events := make(map[string]event.Event)
+	for _, e := range t.clientEvents(stats) {
+		events[e.Key()] = e
+	}
+	if err := c.SendEvents(events); nil != err {
+		return err
+	}
+	t.client = stats
+	return nil
+}
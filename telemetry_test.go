@@ -0,0 +1,155 @@
+package statsd
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/quipo/statsd/statsdtest"
+)
+
+func TestClientStats(t *testing.T) {
+	srv, err := statsdtest.NewServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	client := NewStatsdClient(srv.Addr, "test.")
+	client.Incr("a", 1) // not connected yet
+	if err = client.CreateSocket(); nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.Incr("a", 1)
+	client.Gauge("g", 10)
+
+	expected := ClientStats{PacketsSent: 2, BytesWritten: 21, WriteErrors: 1}
+	if actual := client.Stats(); expected != actual {
+		t.Errorf("wrong stats: Expected: %+v, Actual: %+v", expected, actual)
+	}
+}
+
+func TestBufferTelemetry(t *testing.T) {
+	srv, err := statsdtest.NewServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	client, err := NewStatsdClientWithOptions(srv.Addr, "test.", WithPayloadSize(1432))
+	if nil != err {
+		t.Fatal(err)
+	}
+	buffered, err := NewStatsdBufferWithOptions(time.Hour, client,
+		WithTelemetry(""),
+		WithVerbose(false),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	buffered.Incr("a", 1)
+	buffered.Incr("a", 2)
+	buffered.Gauge("g", 5)
+	if err = buffered.Close(); nil != err {
+		t.Fatal(err)
+	}
+
+	// 2 metrics and 9 about the client itself
+	if err = srv.WaitFor(11); nil != err {
+		t.Fatal(err)
+	}
+	srv.AssertCounter(t, "test.a", 3)
+	srv.AssertCounter(t, "test.statsd.client.events_received", 3)
+	srv.AssertCounter(t, "test.statsd.client.events_dropped", 0)
+	srv.AssertCounter(t, "test.statsd.client.flush_errors", 0)
+	srv.AssertCounter(t, "test.statsd.client.packets_sent", 0)
+	srv.AssertCounter(t, "test.statsd.client.write_errors", 0)
+	srv.AssertGauge(t, "test.statsd.client.flush_size", 0) // no previous flush
+
+	stats := buffered.Stats()
+	if 3 != stats.EventsReceived || 1 != stats.Flushes || 0 != stats.FlushErrors || 11 != stats.LastFlushSize {
+		t.Errorf("wrong stats: %+v", stats)
+	}
+	if stats.LastFlushDuration <= 0 {
+		t.Errorf("the flush duration should be recorded: %v", stats.LastFlushDuration)
+	}
+	if 1 != client.Stats().PacketsSent {
+		t.Errorf("wrong stats: %+v", client.Stats())
+	}
+}
+
+func TestBufferTelemetryPending(t *testing.T) {
+	buffered, err := NewStatsdBufferWithOptions(time.Hour, &flakyClient{}, WithTelemetry("t"), WithVerbose(false))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer buffered.Close()
+
+	// the flush of the first counters failed: they are still pending with the next ones
+	atomic.AddUint64(&buffered.stats.EventsReceived, 2)
+	buffered.addTelemetry()
+	atomic.AddUint64(&buffered.stats.EventsReceived, 3)
+	buffered.addTelemetry()
+	if e, ok := buffered.events["telemetry|t.events_received"]; !ok || int64(5) != e.Payload() {
+		t.Errorf("wrong pending counter: %v", e)
+	}
+}
+
+func TestClientTelemetry(t *testing.T) {
+	client, err := NewStatsdClientWithOptions("127.0.0.1:1201", "test.", WithTelemetry(""), WithPayloadSize(1432))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.stopTelemetry() // sent by hand below
+	conn := client.conn
+	client.conn = nil
+
+	// not connected: the counters are sent again with the next ones
+	if err = client.sendTelemetry(); nil == err {
+		t.Fatal("expected an error sending without a connection")
+	}
+	mock := &MockNetConn{}
+	client.conn = mock
+	conn.Close()
+	client.Incr("a", 1)
+	if err = client.sendTelemetry(); nil != err {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(mock.buf.String()), "\n")
+	sort.Strings(lines)
+	expected := []string{
+		"test.a:1|c",
+		"test.statsd.client.bytes_written:11|c",
+		"test.statsd.client.packets_sent:1|c",
+		"test.statsd.client.write_errors:1|c",
+	}
+	if strings.Join(expected, ",") != strings.Join(lines, ",") {
+		t.Errorf("did not receive all metrics: Expected: %T %v, Actual: %T %v ", expected, expected, lines, lines)
+	}
+}
+
+func TestClientTelemetryInterval(t *testing.T) {
+	srv, err := statsdtest.NewServer()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	client, err := NewStatsdClientWithOptions(srv.Addr, "test.",
+		WithTelemetry(""),
+		WithTelemetryInterval(10*time.Millisecond),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for 0 == len(srv.Find("test.statsd.client.packets_sent", "c")) {
+		if err = srv.WaitFor(len(srv.Metrics()) + 1); nil != err {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package statsd
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxTemplates is the number of expanded names cached per client: beyond it,
+// the cache is emptied, so that names built at runtime don't grow it forever
+const maxTemplates = 10000
+
+// placeholders registered with RegisterPlaceholder, and their version,
+// to invalidate the expanded names when they change
+var (
+	placeholdersMu      sync.RWMutex
+	placeholders        = make(map[string]string)
+	placeholdersVersion uint64
+)
+
+// RegisterPlaceholder makes %NAME% expand to the value in the prefixes and in the metric names,
+// e.g. RegisterPlaceholder("DC", "eu-west") to send "myproject.eu-west.requests" for "%DC%.requests".
+// The built-in placeholders (%HOST%, %SHORTHOST%, %PID% and %ENV{VAR}%) can't be replaced.
+// The prefixes are expanded when the clients are created, so register the placeholders first.
+func RegisterPlaceholder(name string, value string) {
+	placeholdersMu.Lock()
+	placeholders[name] = value
+	placeholdersMu.Unlock()
+	atomic.AddUint64(&placeholdersVersion, 1)
+}
+
+// placeholder returns the value of a placeholder (without the % delimiters)
+func placeholder(name string, hostname string) (string, bool) {
+	switch {
+	case "HOST" == name:
+		return hostname, true
+	case "SHORTHOST" == name:
+		if i := strings.IndexByte(hostname, '.'); i >= 0 {
+			return hostname[:i], true
+		}
+		return hostname, true
+	case "PID" == name:
+		return strconv.Itoa(os.Getpid()), true
+	case strings.HasPrefix(name, "ENV{") && strings.HasSuffix(name, "}"):
+		return os.Getenv(name[4 : len(name)-1]), true
+	}
+	placeholdersMu.RLock()
+	defer placeholdersMu.RUnlock()
+	value, ok := placeholders[name]
+	return value, ok
+}
+
+// expandTemplate replaces all the placeholders in a name. The unknown ones are left as they are.
+func expandTemplate(name string, hostname string) string {
+	i := strings.IndexByte(name, '%')
+	if i < 0 {
+		return name
+	}
+	var b strings.Builder
+	for i >= 0 {
+		j := strings.IndexByte(name[i+1:], '%')
+		if j < 0 {
+			break
+		}
+		j += i + 1
+		if value, ok := placeholder(name[i+1:j], hostname); ok {
+			b.WriteString(name[:i])
+			b.WriteString(value)
+			name = name[j+1:]
+			i = strings.IndexByte(name, '%')
+			continue
+		}
+		// not a placeholder: the closing % might open the next one
+		b.WriteString(name[:j])
+		name = name[j:]
+		i = 0
+	}
+	b.WriteString(name)
+	return b.String()
+}
+
+// templates caches the expanded metric names of a client, so that the templates
+// are only expanded once per name, and the names without placeholders cost nothing
+type templates struct {
+	mu       sync.RWMutex
+	hostname string
+	version  uint64
+	names    map[string]string
+}
+
+// expand returns the name with its placeholders replaced
+func (t *templates) expand(name string, hostname string) string {
+	if strings.IndexByte(name, '%') < 0 {
+		return name
+	}
+	version := atomic.LoadUint64(&placeholdersVersion)
+	t.mu.RLock()
+	expanded, ok := t.names[name]
+	ok = ok && hostname == t.hostname && version == t.version
+	t.mu.RUnlock()
+	if ok {
+		return expanded
+	}
+
+	expanded = expandTemplate(name, hostname)
+	t.mu.Lock()
+	if nil == t.names || len(t.names) >= maxTemplates || hostname != t.hostname || version != t.version {
+		// the hostname or the placeholders changed
+		t.names = make(map[string]string)
+		t.hostname = hostname
+		t.version = version
+	}
+	t.names[name] = expanded
+	t.mu.Unlock()
+	return expanded
+}
+
+// globalTemplates caches the expanded names of the clients using the global Hostname
+var globalTemplates templates
+
+// expandGlobal returns the name with its placeholders replaced, using the global Hostname
+func expandGlobal(name string) string {
+	return globalTemplates.expand(name, Hostname)
+}
+
+// expandLine replaces the name of an event at the start of one of its lines with the expanded name
+func expandLine(line string, key string, expanded string) string {
+	if expanded != key && strings.HasPrefix(line, key) {
+		return expanded + line[len(key):]
+	}
+	return line
+}
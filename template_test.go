@@ -0,0 +1,94 @@
+package statsd
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/quipo/statsd/event"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	os.Setenv("STATSD_TEST_ENV", "staging")
+	defer os.Unsetenv("STATSD_TEST_ENV")
+	RegisterPlaceholder("TEST_DC", "eu-west")
+	pid := strconv.Itoa(os.Getpid())
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"req", "req"},
+		{"%HOST%.req", "web-1.example.com.req"},
+		{"%HOST%.%HOST%", "web-1.example.com.web-1.example.com"},
+		{"%SHORTHOST%.req", "web-1.req"},
+		{"worker.%PID%", "worker." + pid},
+		{"%ENV{STATSD_TEST_ENV}%.req", "staging.req"},
+		{"%ENV{STATSD_TEST_UNSET}%.req", ".req"},
+		{"%TEST_DC%.%SHORTHOST%", "eu-west.web-1"},
+		{"100%.%HOST%", "100%.web-1.example.com"}, // not a placeholder
+		{"%unknown%HOST%", "%unknownweb-1.example.com"},
+		{"50%", "50%"},
+	}
+	for _, tt := range tests {
+		if actual := expandTemplate(tt.name, "web-1.example.com"); tt.expected != actual {
+			t.Errorf("%s: Expected: %s, Actual: %s", tt.name, tt.expected, actual)
+		}
+	}
+}
+
+func TestTemplatesCache(t *testing.T) {
+	var names templates
+	if actual := names.expand("%TEST_ZONE%.req", "host1"); "%TEST_ZONE%.req" != actual {
+		t.Errorf("unexpected name: %s", actual)
+	}
+	// the cache is invalidated when the placeholders or the hostname change
+	RegisterPlaceholder("TEST_ZONE", "a")
+	if actual := names.expand("%TEST_ZONE%.req", "host1"); "a.req" != actual {
+		t.Errorf("unexpected name: %s", actual)
+	}
+	if actual := names.expand("%HOST%.req", "host2"); "host2.req" != actual {
+		t.Errorf("unexpected name: %s", actual)
+	}
+	if actual := names.expand("%HOST%.req", "host3"); "host3.req" != actual {
+		t.Errorf("unexpected name: %s", actual)
+	}
+}
+
+func TestClientTemplates(t *testing.T) {
+	client, err := NewStatsdClientWithOptions("127.0.0.1:8125", "%SHORTHOST%.",
+		WithHostname("web-1.example.com"),
+		WithPayloadSize(1432),
+	)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	mock := &MockNetConn{}
+	client.conn = mock
+
+	client.Incr("%HOST%.req.%HOST%", 1)
+	if expected := "web-1.web-1.example.com.req.web-1.example.com:1|c"; expected != strings.TrimSpace(mock.buf.String()) {
+		t.Errorf("unexpected metric: Expected: %q, Actual: %q", expected, mock.buf.String())
+	}
+
+	mock.buf.Reset()
+	client.SendEvents(map[string]event.Event{
+		"t": &event.Timing{Name: "db.%SHORTHOST%", Value: 5, Count: 1, Min: 5, Max: 5},
+	})
+	lines := strings.Split(strings.TrimSpace(mock.buf.String()), "\n")
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "web-1.db.web-1.") {
+			t.Errorf("unexpected metric: %s", line)
+		}
+	}
+}
+
+func BenchmarkExpandName(b *testing.B) {
+	client := NewStatsdClient("127.0.0.1:8125", "test.")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		client.expand("%SHORTHOST%.requests")
+	}
+}